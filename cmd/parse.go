@@ -17,6 +17,7 @@ package cmd
 import (
 	"encoding/json"
 	"github.com/Fjolnir-Dvorak/modsecParser/modsecure"
+	"github.com/pkg/errors"
 	"os"
 	"path"
 	"strconv"
@@ -25,6 +26,10 @@ import (
 )
 
 
+const (
+	stdinFilename = "-"
+)
+
 var (
 	sortUrls      bool
 	sortStatus    bool
@@ -34,6 +39,17 @@ var (
 	fileMap = make(map[string]*os.File)
 	lossyMode     bool
 	persistErrors bool
+	decodeResponseBodies bool
+	dialectName   string
+	formatName    string
+	concurrentMode bool
+	storageDir    string
+	stateFile     string
+	workers       int
+	limits        modsecure.Limits
+	reassemblyWindow int
+	rawLineEndings bool
+	provenance    bool
 )
 
 // parseCmd represents the parse command
@@ -56,38 +72,116 @@ func init() {
 	parseCmd.Flags().BoolVarP(&sortUrls, "sortByUrls", "u", false, "sorts by urls")
 	parseCmd.Flags().BoolVarP(&sortStatus, "sortByStatusCodes", "s", false, "sorts by HTTP status code")
 	parseCmd.Flags().BoolVarP(&sortMethod, "sortByMethod", "m", false, "sorts")
-	parseCmd.Flags().StringSliceVarP(&fileList, "files", "f", nil, "files to parse")
-	parseCmd.MarkFlagRequired("files")
+	parseCmd.Flags().StringSliceVarP(&fileList, "files", "f", nil, "files to parse, plain or compressed with gzip or bzip2. \"-\" reads from stdin. Defaults to stdin")
 	parseCmd.Flags().StringVarP(&outDirectory, "out", "o", "out/", "output directory")
 	parseCmd.MarkFlagRequired("out")
 	parseCmd.Flags().BoolVarP(&lossyMode, "lossyMode", "l", false, "Turnes on lossy mode. Default stops parsing on error")
 	parseCmd.Flags().BoolVarP(&persistErrors, "persistErrors", "p", false, "Persists parse errors on lossy mode")
+	parseCmd.Flags().StringVar(&dialectName, "dialect", "auto", "Dialect of the audit log: auto, v2 (ModSecurity 2.x) or v3 (libmodsecurity 3)")
+	parseCmd.Flags().StringVar(&formatName, "format", "serial", "Format of the audit log: serial or json (SecAuditLogFormat JSON)")
+	parseCmd.Flags().BoolVarP(&concurrentMode, "concurrent", "c", false, "The files are index files of a concurrent audit log (SecAuditLogType Concurrent)")
+	parseCmd.Flags().StringVar(&storageDir, "storageDir", "", "Directory of the transaction files of a concurrent audit log. Defaults to the directory of the index file")
+	parseCmd.Flags().StringVar(&stateFile, "stateFile", "", "Saves the position after the handled records into this file and resumes from it on the next run. Can not be used with --reassemblyWindow")
+	parseCmd.Flags().IntVarP(&workers, "workers", "w", 1, "Parses serial audit logs with this many goroutines in lossy mode, 0 uses one per CPU")
+	parseCmd.Flags().IntVar(&limits.MaxLineSize, "maxLineSize", 0, "Maximal size of a line in bytes, 0 is no limit")
+	parseCmd.Flags().IntVar(&limits.MaxSectionSize, "maxSectionSize", 0, "Maximal size of a section body in bytes, 0 is no limit")
+	parseCmd.Flags().IntVar(&limits.MaxRecordSize, "maxRecordSize", 0, "Maximal size of a record in bytes, 0 is no limit")
+	parseCmd.Flags().BoolVar(&limits.Truncate, "truncate", false, "Truncates records which exceed a maximal size instead of rejecting them")
+	parseCmd.Flags().IntVar(&reassemblyWindow, "reassemblyWindow", 0, "Reassembles records whose sections are interleaved, a record is given up after this many sections of other records. 0 turns it off")
+	parseCmd.Flags().BoolVar(&rawLineEndings, "rawLineEndings", false, "Keeps the original line breaks in the persisted parse errors instead of \"\\n\"")
+	parseCmd.Flags().BoolVar(&provenance, "provenance", false, "Adds the file, the positions and the exact bytes of every record to its output")
+	parseCmd.Flags().BoolVarP(&decodeResponseBodies, "decodeResponseBodies", "d", false, "Removes chunked and compressed encodings from response bodies")
 }
 
 func doParseAction(cmd *cobra.Command, args []string) {
 	defer closeFileMap()
+	dialect, err := modsecure.ParseDialect(dialectName)
+	if err != nil {
+		panic(err)
+	}
+	format, err := modsecure.ParseFormat(formatName)
+	if err != nil {
+		panic(err)
+	}
+	if len(fileList) == 0 {
+		fileList = []string{stdinFilename}
+	}
+	if stateFile != "" && reassemblyWindow > 0 {
+		// The state only holds the position after a record, records of the reassembly window which were read
+		// before that position would be given again after resuming.
+		panic(errors.New("--stateFile can not be used together with --reassemblyWindow"))
+	}
+	var state *readState
+	if stateFile != "" {
+		state, err = loadState(stateFile)
+		if err != nil {
+			panic(err)
+		}
+		defer state.save()
+	}
 	for _, elem := range fileList {
-		reader, err := modsecure.CreateRecordReader(elem, false)
+		reader, err := createParseReader(elem)
 		if err != nil {
 			panic(err)
 		}
-		filename := path.Base(elem)
+		reader.SetDecodeResponseBodies(decodeResponseBodies)
+		reader.SetDialect(dialect)
+		reader.SetFormat(format)
+		reader.SetLimits(limits)
+		reader.SetReassembly(reassemblyWindow)
+		reader.SetRawLineEndings(rawLineEndings)
+		reader.SetProvenance(provenance)
+		// stdin can not be resumed, it starts over every time.
+		keepState := state != nil && elem != stdinFilename
+		if keepState {
+			err = state.resume(reader, elem)
+			if err != nil {
+				panic(err)
+			}
+		}
+		filename := path.Base(reader.Name())
 		if lossyMode {
-			for recordAndRaw := range reader.IterLossy() {
+			var records <-chan *modsecure.RecordAndRaw
+			if workers == 1 {
+				records = reader.IterLossy()
+			} else {
+				records = reader.IterParallel(workers)
+			}
+			for recordAndRaw := range records {
 				if recordAndRaw.Record != nil {
 					saveRecord(recordAndRaw.Record, filename)
 				} else {
 					saveError(recordAndRaw.Raw, filename)
 				}
+				if keepState {
+					state.update(elem, recordAndRaw.ResumePosition)
+				}
 			}
 		} else {
 			for record := range reader.Iter() {
 				saveRecord(record, filename)
+				if keepState {
+					state.update(elem, record.ResumePosition)
+				}
 			}
 		}
 	}
 }
 
+// createParseReader creates the reader for an entry of --files, "-" is stdin.
+func createParseReader(filename string) (reader *modsecure.RecordReader, err error) {
+	if filename == stdinFilename {
+		if concurrentMode {
+			return modsecure.CreateConcurrentRecordReaderFromReader(os.Stdin, "stdin", storageDir, false)
+		}
+		return modsecure.CreateRecordReaderFromReader(os.Stdin, "stdin", false)
+	}
+	if concurrentMode {
+		return modsecure.CreateConcurrentRecordReader(filename, storageDir, false)
+	}
+	return modsecure.CreateRecordReader(filename, false)
+}
+
 func saveError(payload string, filename string) {
 	savePath := composeErrorPath(outDirectory)
 	appendToFile(savePath, filename, []byte(payload))
@@ -0,0 +1,78 @@
+// Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Fjolnir-Dvorak/modsecParser/modsecure"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	showFileList   []string
+	showFormatName string
+)
+
+// showCmd represents the show command
+var showCmd = &cobra.Command{
+	Use:   "show <transaction-id>",
+	Short: "Prints the record of a transaction as JSON",
+	Long: `Searches the record of a transaction in the given audit logs and prints it as JSON.
+The transaction is either the unique ID of Section A or the ID of the section boundaries.
+Every log gets a sidecar index (<log>.idx), which is reused as long as the log is not changed.`,
+	Args: cobra.ExactArgs(1),
+	Run:  doShowAction,
+}
+
+func init() {
+	RootCmd.AddCommand(showCmd)
+
+	showCmd.Flags().StringSliceVarP(&showFileList, "files", "f", nil, "files to search")
+	showCmd.MarkFlagRequired("files")
+	showCmd.Flags().StringVar(&showFormatName, "format", "serial", "Format of the audit logs: serial or json (SecAuditLogFormat JSON)")
+}
+
+func doShowAction(cmd *cobra.Command, args []string) {
+	format, err := modsecure.ParseFormat(showFormatName)
+	if err != nil {
+		panic(err)
+	}
+	transactionID := args[0]
+	for _, elem := range showFileList {
+		index, err := modsecure.LoadOrBuildOffsetIndex(elem, false, format)
+		if err != nil {
+			panic(err)
+		}
+		entry := index.Lookup(transactionID)
+		if entry == nil {
+			continue
+		}
+		record, err := index.ReadRecord(entry)
+		if err != nil {
+			panic(err)
+		}
+		payload, err := json.MarshalIndent(record, "", "  ")
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(payload))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Transaction %s not found\n", transactionID)
+	os.Exit(1)
+}
@@ -0,0 +1,176 @@
+// Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/Fjolnir-Dvorak/modsecParser/modsecure"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// stateSaveInterval is the time between two saves of the state file. After a crash the records of the last
+	// interval are read again.
+	stateSaveInterval = time.Second
+	// fingerprintSize is the number of bytes at the start of a log which identify it.
+	fingerprintSize = 1024
+)
+
+// readState is persisted in the --stateFile of the parse and tail commands. It holds the position after
+// the last handled record of every log, so a restarted command does not skip records. It repeats the records
+// of at most the last stateSaveInterval after a crash.
+type readState struct {
+	filename string
+	lastSave time.Time
+	// changed are the logs whose position was updated since the last save.
+	changed map[string]bool
+	Files   map[string]fileState `json:"files"`
+}
+
+// fileState is the saved position of a log. The fingerprint identifies the log the position belongs to, a
+// different log under the same name, e.g. after a rotation, is read from its start.
+type fileState struct {
+	modsecure.Position
+	// Fingerprint is the SHA-256 of the first FingerprintSize bytes of the log.
+	Fingerprint     string `json:"fingerprint,omitempty"`
+	FingerprintSize int64  `json:"fingerprintSize,omitempty"`
+}
+
+// loadState reads the state file. A missing file is an empty state.
+func loadState(filename string) (state *readState, err error) {
+	state = &readState{
+		filename: filename,
+		changed:  make(map[string]bool),
+		Files:    make(map[string]fileState),
+	}
+	payload, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(payload, state)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func stateKey(logFile string) string {
+	absolute, err := filepath.Abs(logFile)
+	if err != nil {
+		return logFile
+	}
+	return absolute
+}
+
+// fingerprint hashes up to size bytes from the start of logFile, read are the hashed bytes.
+func fingerprint(logFile string, size int64) (hash string, read int64, err error) {
+	file, err := os.Open(logFile)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+	hasher := sha256.New()
+	read, err = io.Copy(hasher, io.LimitReader(file, size))
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), read, nil
+}
+
+// resume continues reader at the saved position of logFile. The position is only used if logFile still
+// starts with the bytes it had when the position was saved, otherwise reading starts over.
+func (s *readState) resume(reader *modsecure.RecordReader, logFile string) (err error) {
+	saved, ok := s.Files[stateKey(logFile)]
+	if !ok {
+		return nil
+	}
+	if saved.Fingerprint != "" {
+		hash, read, err := fingerprint(logFile, saved.FingerprintSize)
+		if err != nil {
+			return err
+		}
+		if read != saved.FingerprintSize || hash != saved.Fingerprint {
+			return nil
+		}
+	}
+	return reader.SkipTo(saved.Position)
+}
+
+// update notes the position of logFile. The state file is saved at most every stateSaveInterval, see save.
+func (s *readState) update(logFile string, position modsecure.Position) {
+	key := stateKey(logFile)
+	saved := s.Files[key]
+	saved.Position = position
+	s.Files[key] = saved
+	s.changed[key] = true
+	if time.Since(s.lastSave) >= stateSaveInterval {
+		s.save()
+	}
+}
+
+// save writes the state file if a position changed. The file is synced and replaced atomically, so a crash
+// leaves either the old or the new state.
+func (s *readState) save() {
+	if len(s.changed) == 0 {
+		return
+	}
+	for key := range s.changed {
+		saved := s.Files[key]
+		hash, read, err := fingerprint(key, fingerprintSize)
+		if err != nil {
+			panic(err)
+		}
+		saved.Fingerprint, saved.FingerprintSize = hash, read
+		s.Files[key] = saved
+	}
+	payload, err := json.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	temporary := s.filename + ".tmp"
+	file, err := os.OpenFile(temporary, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		panic(err)
+	}
+	_, err = file.Write(payload)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		panic(err)
+	}
+	err = os.Rename(temporary, s.filename)
+	if err != nil {
+		panic(err)
+	}
+	// The rename is only durable once the directory is synced, which is not possible on every platform.
+	if directory, err := os.Open(filepath.Dir(s.filename)); err == nil {
+		directory.Sync()
+		directory.Close()
+	}
+	s.changed = make(map[string]bool)
+	s.lastSave = time.Now()
+}
@@ -0,0 +1,116 @@
+// Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Fjolnir-Dvorak/modsecParser/modsecure"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	tailFile                 string
+	tailFromStart            bool
+	tailDialectName          string
+	tailFormatName           string
+	tailDecodeResponseBodies bool
+	tailStateFile            string
+)
+
+// tailCmd represents the tail command
+var tailCmd = &cobra.Command{
+	Use:   "tail",
+	Short: "Follows a live audit log and prints every record as JSON",
+	Long: `Follows an audit log while ModSecurity writes to it, like "tail -F".
+Every record is printed as one line of JSON to stdout as soon as it is complete,
+records which could not be parsed are printed to stderr.
+The log may be rotated by renaming or by truncation (copytruncate).`,
+	Run: doTailAction,
+}
+
+func init() {
+	RootCmd.AddCommand(tailCmd)
+
+	tailCmd.Flags().StringVarP(&tailFile, "file", "f", "", "audit log to follow")
+	tailCmd.MarkFlagRequired("file")
+	tailCmd.Flags().BoolVarP(&tailFromStart, "fromStart", "s", false, "Starts with the records already in the file. Default starts at its end")
+	tailCmd.Flags().StringVar(&tailDialectName, "dialect", "auto", "Dialect of the audit log: auto, v2 (ModSecurity 2.x) or v3 (libmodsecurity 3)")
+	tailCmd.Flags().StringVar(&tailFormatName, "format", "serial", "Format of the audit log: serial or json (SecAuditLogFormat JSON)")
+	tailCmd.Flags().StringVar(&tailStateFile, "stateFile", "", "Saves the position after the handled records into this file and resumes from it on the next run")
+	tailCmd.Flags().BoolVarP(&tailDecodeResponseBodies, "decodeResponseBodies", "d", false, "Removes chunked and compressed encodings from response bodies")
+}
+
+func doTailAction(cmd *cobra.Command, args []string) {
+	dialect, err := modsecure.ParseDialect(tailDialectName)
+	if err != nil {
+		panic(err)
+	}
+	format, err := modsecure.ParseFormat(tailFormatName)
+	if err != nil {
+		panic(err)
+	}
+	var state *readState
+	fromEnd := !tailFromStart
+	if tailStateFile != "" {
+		state, err = loadState(tailStateFile)
+		if err != nil {
+			panic(err)
+		}
+		defer state.save()
+		if _, ok := state.Files[stateKey(tailFile)]; ok {
+			fromEnd = false
+		}
+	}
+	reader, err := modsecure.CreateFollowRecordReader(tailFile, false, fromEnd)
+	if err != nil {
+		panic(err)
+	}
+	defer reader.Close()
+	if state != nil {
+		err = state.resume(reader, tailFile)
+		if err != nil {
+			panic(err)
+		}
+	}
+	reader.SetDecodeResponseBodies(tailDecodeResponseBodies)
+	reader.SetDialect(dialect)
+	reader.SetFormat(format)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	encoder := json.NewEncoder(os.Stdout)
+	for recordAndRaw := range reader.IterLossyContext(ctx) {
+		if recordAndRaw.Record == nil {
+			fmt.Fprint(os.Stderr, recordAndRaw.Raw)
+		} else {
+			err = encoder.Encode(recordAndRaw.Record)
+			if err != nil {
+				panic(err)
+			}
+		}
+		if state != nil {
+			state.update(tailFile, recordAndRaw.ResumePosition)
+		}
+	}
+	if reader.Err != nil && reader.Err != context.Canceled {
+		panic(reader.Err)
+	}
+}
@@ -0,0 +1,89 @@
+package modsecure
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+)
+
+// Position is a place in an audit log at which reading can be continued, e.g. after a restart.
+type Position struct {
+	// Offset counts the bytes from the start of the (decompressed) log.
+	Offset int64 `json:"offset"`
+	// Line counts the lines before Offset.
+	Line int `json:"line"`
+}
+
+// Position returns the position after the last line which belongs to a returned record.
+// In follow mode the position refers to the current file, it starts over after a rotation.
+func (r *RecordReader) Position() Position {
+	buffer := r.buffer
+	position := buffer.position()
+	if r.reassembler != nil {
+		if start, ok := r.reassembler.oldestStart(); ok && start.Offset < position.Offset {
+			position = start
+		}
+	}
+	return r.filePosition(position)
+}
+
+// filePosition turns a position of the buffer into one of the current file, they differ in follow mode after a
+// rotation. A position in a previous file is the start of the current file.
+func (r *RecordReader) filePosition(position Position) Position {
+	if follower, ok := r.buffer.closer.(*followReader); ok {
+		start := follower.startOfFile()
+		if position.Offset < start.Offset {
+			return Position{}
+		}
+		position.Offset -= start.Offset
+		position.Line -= start.Line
+	}
+	return position
+}
+
+// position returns the position after the last accepted line, a peeked line is not counted.
+func (r *readBuffer) position() Position {
+	position := Position{
+		Offset: r.readOffset,
+		Line:   r.linePointer,
+	}
+	if r.hasLastReadLine {
+		position.Offset -= int64(r.lastReadLineLength)
+	}
+	return position
+}
+
+// SkipTo continues reading at position, which was returned by Position or as ResumePosition before.
+// It has to be called before the first record is read. Uncompressed files are seeked, all other
+// sources are read up to position. For a followed file which got shorter than position, e.g. because it
+// was rotated in the meantime, reading starts at the beginning of the file.
+func (r *RecordReader) SkipTo(position Position) (err error) {
+	buffer := r.buffer
+	if buffer.readOffset != 0 || buffer.linePointer != 0 {
+		return errors.New("SkipTo must be called before reading")
+	}
+	if position.Offset < 0 || position.Line < 0 {
+		return errors.New(fmt.Sprintf("Invalid position: %d, line %d", position.Offset, position.Line))
+	}
+	if buffer.seekable != nil {
+		offset, err := buffer.seekable.Seek(position.Offset, io.SeekStart)
+		if err != nil {
+			return errors.WithMessage(err, "Failed to seek")
+		}
+		if offset != position.Offset {
+			position = Position{Offset: offset}
+		}
+		buffer.reader.Reset(buffer.seekable)
+	} else {
+		skipped, err := io.CopyN(io.Discard, buffer.reader, position.Offset)
+		if err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("Failed to skip to offset %d, the source ends at %d", position.Offset, skipped))
+		}
+	}
+	buffer.readOffset = position.Offset
+	buffer.linePointer = position.Line
+	if follower, ok := buffer.closer.(*followReader); ok {
+		follower.startAtLine(position.Line)
+	}
+	return nil
+}
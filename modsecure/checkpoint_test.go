@@ -0,0 +1,134 @@
+package modsecure
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordReader_SkipTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		format   EFormat
+	}{
+		{name: "Serial", filename: "testdata/multiSection/v3_records.txt"},
+		{name: "Compressed", filename: "testdata/compressed/v3_records.txt.gz"},
+		{name: "JSON", filename: "testdata/json/v2_records.txt", format: FormatJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := CreateRecordReader(tt.filename, false)
+			if err != nil {
+				t.Errorf("CreateRecordReader() error = %v", err)
+				return
+			}
+			defer r.Close()
+			r.SetFormat(tt.format)
+			first, err := r.Next(&strings.Builder{})
+			if err != nil {
+				t.Errorf("RecordReader.Next() error = %v", err)
+				return
+			}
+			second, err := r.Next(&strings.Builder{})
+			if err != nil {
+				t.Errorf("RecordReader.Next() error = %v", err)
+				return
+			}
+
+			resumed, err := CreateRecordReader(tt.filename, false)
+			if err != nil {
+				t.Errorf("CreateRecordReader() error = %v", err)
+				return
+			}
+			defer resumed.Close()
+			resumed.SetFormat(tt.format)
+			err = resumed.SkipTo(first.ResumePosition)
+			if err != nil {
+				t.Errorf("RecordReader.SkipTo() error = %v", err)
+				return
+			}
+			if resumed.Position() != first.ResumePosition {
+				t.Errorf("RecordReader.Position() = %v, want %v", resumed.Position(), first.ResumePosition)
+			}
+			got, err := resumed.Next(&strings.Builder{})
+			if err != nil {
+				t.Errorf("RecordReader.Next() after SkipTo error = %v", err)
+				return
+			}
+			if got.Id != second.Id || got.RecordLine != second.RecordLine || got.ResumePosition != second.ResumePosition {
+				t.Errorf("RecordReader.Next() after SkipTo = %s line %d %v, want %s line %d %v", got.Id, got.RecordLine, got.ResumePosition, second.Id, second.RecordLine, second.ResumePosition)
+			}
+			if resumed.HasNext() {
+				t.Errorf("RecordReader.HasNext() = true after the last record")
+			}
+			err = resumed.SkipTo(first.ResumePosition)
+			if err == nil {
+				t.Errorf("RecordReader.SkipTo() after reading, want error")
+			}
+		})
+	}
+}
+
+func TestRecordReader_Position(t *testing.T) {
+	filename := "testdata/multiSection/v3_records.txt"
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Errorf("os.Stat() error = %v", err)
+		return
+	}
+	r, err := CreateRecordReader(filename, false)
+	if err != nil {
+		t.Errorf("CreateRecordReader() error = %v", err)
+		return
+	}
+	var last *Record
+	for record := range r.Iter() {
+		last = record
+	}
+	if last == nil || last.ResumePosition.Offset != info.Size() {
+		t.Errorf("last Record.ResumePosition = %v, want offset %d", last.ResumePosition, info.Size())
+	}
+}
+
+func TestRecordReader_SkipToBeyondEnd(t *testing.T) {
+	r, err := CreateRecordReader("testdata/compressed/v3_records.txt.gz", false)
+	if err != nil {
+		t.Errorf("CreateRecordReader() error = %v", err)
+		return
+	}
+	defer r.Close()
+	if err = r.SkipTo(Position{Offset: 1 << 20}); err == nil {
+		t.Errorf("RecordReader.SkipTo() beyond the end, want error")
+	}
+}
+
+func TestCreateFollowRecordReader_SkipTo(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "modsec_audit.log")
+	appendTestFile(t, filename, followTestRecord("00000001"))
+	appendTestFile(t, filename, followTestRecord("00000002"))
+	tests := []struct {
+		name     string
+		position Position
+		wantId   string
+	}{
+		{name: "Resume", position: Position{Offset: int64(len(followTestRecord("00000001"))), Line: 11}, wantId: "00000002"},
+		{name: "Rotated while stopped", position: Position{Offset: 1 << 20, Line: 10000}, wantId: "00000001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := CreateFollowRecordReader(filename, false, false)
+			if err != nil {
+				t.Fatalf("CreateFollowRecordReader() error = %v", err)
+			}
+			defer r.Close()
+			r.buffer.closer.(*followReader).pollInterval = 10 * time.Millisecond
+			if err = r.SkipTo(tt.position); err != nil {
+				t.Fatalf("RecordReader.SkipTo() error = %v", err)
+			}
+			expectFollowedRecord(t, r.Iter(), tt.wantId)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package modsecure
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	indexQuotedValue = `"(?:[^"\\]|\\.)*"`
+)
+
+var (
+	// parses a line of the index file written with "SecAuditLogType Concurrent", ModSecurity 2:
+	// example.com 192.168.1.10 - - [09/Oct/2018:11:45:38 +0200] "GET / HTTP/1.1" 200 1234 "-" "curl/7.58.0" W7x4gn8AAQEAAEA1Bv4AAAAA "-" /20181009/20181009-1145/20181009-114538-W7x4gn8AAQEAAEA1Bv4AAAAA 0 2345 md5:4d1b6c5f0b1e9b4e0c8c1b5d6f1e2a3b
+	// libmodsecurity 3 leaves out the local user and does not quote the referer and the session.
+	indexLineRegex = regexp.MustCompile(`^(\S+) (\S+) (\S+) (?:(\S+) )?\[([^\]]+)\] (` + indexQuotedValue + `) (\S+) (\S+) (` + indexQuotedValue + `|\S+) (` + indexQuotedValue + `) (\S+) (` + indexQuotedValue + `|\S+) (\S+) ([0-9]+) ([0-9]+) md5:([0-9a-fA-F]+)$`)
+)
+
+// CreateConcurrentRecordReader reads the index file of an audit log written with "SecAuditLogType Concurrent"
+// and the transaction files it points to. The paths of the index are relative to storageDir
+// (SecAuditLogStorageDir), it defaults to the directory of the index file.
+func CreateConcurrentRecordReader(indexFilename string, storageDir string, debugSkipper bool) (reader *RecordReader, err error) {
+	reader, err = CreateRecordReader(indexFilename, debugSkipper)
+	if err != nil {
+		return nil, err
+	}
+	reader.setStorageDir(storageDir)
+	return reader, nil
+}
+
+// CreateConcurrentRecordReaderFromReader reads the index file of a concurrent audit log from source. storageDir
+// defaults to the working directory.
+func CreateConcurrentRecordReaderFromReader(source io.Reader, name string, storageDir string, debugSkipper bool) (reader *RecordReader, err error) {
+	reader, err = CreateRecordReaderFromReader(source, name, debugSkipper)
+	if err != nil {
+		return nil, err
+	}
+	if storageDir == "" {
+		storageDir = "."
+	}
+	reader.setStorageDir(storageDir)
+	return reader, nil
+}
+
+func (r *RecordReader) setStorageDir(storageDir string) {
+	if storageDir == "" {
+		storageDir = filepath.Dir(r.buffer.Name)
+	}
+	r.storageDir = storageDir
+}
+
+// readIndexedRecord reads the next line of the index file and the record of the transaction file it points to.
+func (r *RecordReader) readIndexedRecord(historyBuffer *strings.Builder) (record *Record, err error) {
+	reader := r.buffer
+	reader.readRecordMutex.Lock()
+	defer reader.readRecordMutex.Unlock()
+	reader.startRecord()
+	for {
+		start := reader.position()
+		line, err := reader.ReadLine()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if err == io.EOF {
+			reader.IsFinished = true
+			if strings.TrimSpace(line) == "" {
+				return nil, ErrEndReached
+			}
+		}
+		reader.writeLine(historyBuffer, line)
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entry, err := parseIndexLine(line)
+		if err != nil {
+			return nil, reader.newParseError(errors.WithMessage(err, fmt.Sprintf("Error in line: %d", reader.linePointer)),
+				NIL, CategoryIndex, start, line)
+		}
+		record, err = r.readTransactionFile(entry, historyBuffer)
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("Error in transaction file of line: %d", reader.linePointer))
+		}
+		record.Index = entry
+		skipEmptyLines(reader, historyBuffer)
+		return record, nil
+	}
+}
+
+func (r *RecordReader) readTransactionFile(entry *IndexEntry, historyBuffer *strings.Builder) (record *Record, err error) {
+	filename := resolveIndexedFile(r.storageDir, entry.File)
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var source io.Reader = file
+	if entry.Size > 0 {
+		source = io.NewSectionReader(file, int64(entry.Offset), int64(entry.Size))
+	}
+	buffer, err := createBufferFromReader(source, filename, r.buffer.DebugSkipper)
+	if err != nil {
+		return nil, err
+	}
+	buffer.DecodeResponseBodies = r.buffer.DecodeResponseBodies
+	buffer.Dialect = r.buffer.Dialect
+	buffer.Format = r.buffer.Format
+	buffer.Limits = r.buffer.Limits
+	buffer.Provenance = r.buffer.Provenance
+	buffer.RawLineEndings = r.buffer.RawLineEndings
+	if entry.Size > 0 {
+		buffer.readOffset = int64(entry.Offset)
+	}
+	start := buffer.position()
+	record, err = readRecordOfFormat(buffer, historyBuffer)
+	if err != nil {
+		return nil, err
+	}
+	if buffer.Provenance {
+		// The lines count from the start of the record in the transaction file.
+		record.Source = buffer.source(start)
+	}
+	// Keeps the detected dialect for the following transaction files.
+	r.buffer.Dialect = buffer.Dialect
+	return record, nil
+}
+
+// resolveIndexedFile finds the transaction file of an index entry. ModSecurity 2 writes the path relative to
+// the storage directory, libmodsecurity 3 writes the full path.
+func resolveIndexedFile(storageDir string, file string) string {
+	joined := filepath.Join(storageDir, filepath.FromSlash(file))
+	if _, err := os.Stat(joined); err != nil && filepath.IsAbs(file) {
+		return file
+	}
+	return joined
+}
+
+func parseIndexLine(line string) (entry *IndexEntry, err error) {
+	parsedLine := indexLineRegex.FindStringSubmatch(line)
+	if parsedLine == nil {
+		return nil, errors.New(fmt.Sprintf("Invalid index line: \"%s\"", line))
+	}
+	entry = &IndexEntry{
+		Hostname:      unquoteIndexValue(parsedLine[1]),
+		RemoteUser:    unquoteIndexValue(parsedLine[3]),
+		LocalUser:     unquoteIndexValue(parsedLine[4]),
+		RequestLine:   unquoteIndexValue(parsedLine[6]),
+		Referer:       unquoteIndexValue(parsedLine[9]),
+		UserAgent:     unquoteIndexValue(parsedLine[10]),
+		TransactionID: parsedLine[11],
+		SessionID:     unquoteIndexValue(parsedLine[12]),
+		File:          parsedLine[13],
+		MD5:           strings.ToLower(parsedLine[16]),
+	}
+	entry.RemoteIP = net.ParseIP(parsedLine[2])
+	if entry.RemoteIP == nil {
+		return nil, newCategoryError(CategoryIPAddress, fmt.Sprintf("Invalid index line, remote IP is broken: %s", parsedLine[2]))
+	}
+	entry.Timestamp, err = time.Parse(layoutDate, parsedLine[5])
+	if err != nil {
+		return nil, newCategoryError(CategoryTimestamp, fmt.Sprintf("Invalid index line, time is broken: %s", parsedLine[5]))
+	}
+	status, err := strconv.ParseUint(parsedLine[7], 10, 16)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid index line, status is broken: %s", parsedLine[7]))
+	}
+	entry.Status = uint16(status)
+	// Like "%b" of the Apache access log a "-" is written if no body was sent.
+	if parsedLine[8] != "-" {
+		entry.ResponseSize, err = strconv.ParseUint(parsedLine[8], 10, 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid index line, response size is broken: %s", parsedLine[8]))
+		}
+	}
+	entry.Offset, err = strconv.ParseUint(parsedLine[14], 10, 64)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid index line, offset is broken: %s", parsedLine[14]))
+	}
+	entry.Size, err = strconv.ParseUint(parsedLine[15], 10, 64)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid index line, size is broken: %s", parsedLine[15]))
+	}
+	return entry, nil
+}
+
+// unquoteIndexValue removes the quotes and the escaping of a value of the index line, "-" is an empty value.
+func unquoteIndexValue(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") {
+		value = value[1 : len(value)-1]
+		var unescaped strings.Builder
+		for i := 0; i < len(value); i++ {
+			if value[i] == '\\' && i+1 < len(value) && (value[i+1] == '"' || value[i+1] == '\\') {
+				i++
+			}
+			unescaped.WriteByte(value[i])
+		}
+		value = unescaped.String()
+	}
+	if value == "-" {
+		return ""
+	}
+	return value
+}
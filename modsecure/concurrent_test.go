@@ -0,0 +1,186 @@
+package modsecure
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseIndexLine(t *testing.T) {
+	type args struct {
+		line string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		wantEntry *IndexEntry
+		wantErr   bool
+	}{
+		{
+			name: "ModSecurity 2 index line",
+			args: args{line: `example.com 192.168.1.10 admin - [09/Oct/2018:11:45:38 +0200] "POST /login HTTP/1.1" 403 - "http://example.com/" "curl \"7\"" W7x4gn8AAQEAAEA1Bv4AAAAA "abc" /20181009/20181009-1145/20181009-114538-W7x4gn8AAQEAAEA1Bv4AAAAA 0 2345 md5:4D1B6C5F0B1E9B4E0C8C1B5D6F1E2A3B`},
+			wantEntry: &IndexEntry{
+				Hostname:      "example.com",
+				RemoteIP:      net.ParseIP("192.168.1.10"),
+				RemoteUser:    "admin",
+				Timestamp:     time.Date(2018, time.October, 9, 11, 45, 38, 0, time.FixedZone("", 2*60*60)),
+				RequestLine:   "POST /login HTTP/1.1",
+				Status:        403,
+				Referer:       "http://example.com/",
+				UserAgent:     `curl "7"`,
+				TransactionID: "W7x4gn8AAQEAAEA1Bv4AAAAA",
+				SessionID:     "abc",
+				File:          "/20181009/20181009-1145/20181009-114538-W7x4gn8AAQEAAEA1Bv4AAAAA",
+				Size:          2345,
+				MD5:           "4d1b6c5f0b1e9b4e0c8c1b5d6f1e2a3b",
+			},
+		},
+		{
+			name: "libmodsecurity 3 index line",
+			args: args{line: `localhost 2001:db8::1 - [21/Jan/2020:19:15:36 -0500] "GET /test.pl HTTP/1.1" 200 8 - "curl/7.38.0" 157963413661.219434 - /var/log/modsec/20200121/20200121-1915/20200121-191536-157963413661.219434 0 1024 md5:d41d8cd98f00b204e9800998ecf8427e`},
+			wantEntry: &IndexEntry{
+				Hostname:      "localhost",
+				RemoteIP:      net.ParseIP("2001:db8::1"),
+				Timestamp:     time.Date(2020, time.January, 21, 19, 15, 36, 0, time.FixedZone("", -5*60*60)),
+				RequestLine:   "GET /test.pl HTTP/1.1",
+				Status:        200,
+				ResponseSize:  8,
+				UserAgent:     "curl/7.38.0",
+				TransactionID: "157963413661.219434",
+				File:          "/var/log/modsec/20200121/20200121-1915/20200121-191536-157963413661.219434",
+				Size:          1024,
+				MD5:           "d41d8cd98f00b204e9800998ecf8427e",
+			},
+		},
+		{
+			name:    "Missing md5",
+			args:    args{line: `localhost 127.0.0.1 - [21/Jan/2020:19:15:36 -0500] "GET / HTTP/1.1" 200 8 - "-" 1 - /a 0 1024`},
+			wantErr: true,
+		},
+		{
+			name:    "Broken remote IP",
+			args:    args{line: `localhost 999.0.0.1 - [21/Jan/2020:19:15:36 -0500] "GET / HTTP/1.1" 200 8 - "-" 1 - /a 0 1024 md5:d41d8cd98f00b204e9800998ecf8427e`},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotEntry, err := parseIndexLine(tt.args.line)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseIndexLine() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotEntry, tt.wantEntry) {
+				t.Errorf("parseIndexLine() =\n is   %#v,\n want %#v", gotEntry, tt.wantEntry)
+			}
+		})
+	}
+}
+
+func TestRecordReader_Concurrent(t *testing.T) {
+	r, err := CreateConcurrentRecordReader("testdata/concurrent/index", "", false)
+	if err != nil {
+		t.Errorf("CreateConcurrentRecordReader() error = %v", err)
+		return
+	}
+	records := make([]*Record, 0, 2)
+	for record := range r.Iter() {
+		records = append(records, record)
+	}
+	if r.Err != nil {
+		t.Errorf("RecordReader.Iter() error = %v", r.Err)
+		return
+	}
+	if len(records) != 2 {
+		t.Errorf("RecordReader.Iter() returned %d records, want 2", len(records))
+		return
+	}
+	wantIds := []string{"W7x4gn8AAQEAAEA1Bv4AAAAA", "W7x4gn8AAQEAAEA1Bv4AAAAB"}
+	for i, record := range records {
+		if record.Index == nil || record.Index.TransactionID != wantIds[i] || record.AuditHeader.TransactionID != wantIds[i] {
+			t.Errorf("Record %d: Index = %#v, AuditHeader = %#v", i, record.Index, record.AuditHeader)
+		}
+	}
+	if records[0].Index.ResponseSize != 1234 || records[0].Index.Size != 259 || records[0].RequestHeader.Path != "/index.php?id=1" {
+		t.Errorf("Record 0: Index = %#v, RequestHeader = %#v", records[0].Index, records[0].RequestHeader)
+	}
+	if records[1].ResponseHeader.Status != 403 || records[1].Index.MD5 != "531063f62a985e78ecb04c36ce11b43d" {
+		t.Errorf("Record 1: Index = %#v, ResponseHeader = %#v", records[1].Index, records[1].ResponseHeader)
+	}
+}
+
+func TestRecordReader_ConcurrentMissingFile(t *testing.T) {
+	r, err := CreateConcurrentRecordReader("testdata/concurrent/index", "testdata", false)
+	if err != nil {
+		t.Errorf("CreateConcurrentRecordReader() error = %v", err)
+		return
+	}
+	historyBuffer := &strings.Builder{}
+	_, err = r.Next(historyBuffer)
+	if err == nil {
+		t.Errorf("RecordReader.Next() returned a record of a missing file")
+	}
+	err = r.PeekToNextValidStart(historyBuffer)
+	if err != nil || !r.HasNext() {
+		t.Errorf("RecordReader.PeekToNextValidStart() error = %v, HasNext = %v", err, r.HasNext())
+	}
+	if !strings.HasPrefix(historyBuffer.String(), "example.com 192.168.1.10 ") {
+		t.Errorf("history = %q, want the index line", historyBuffer.String())
+	}
+}
+
+func TestRecordReader_ConcurrentRawLineEndings(t *testing.T) {
+	transaction := strings.ReplaceAll("--7a1c2d3e-A--\n"+
+		"[09/Oct/2018:11:45:38 +0200] W7x4gn8AAQEAAEA1Bv4AAAAC 192.168.1.10 50314 192.168.1.1 80\n"+
+		"--7a1c2d3e-B--\nPOST / HTTP/1.1\nHost: example.com\n\n"+
+		"--7a1c2d3e-C--\na=1\n\n"+
+		"--7a1c2d3e-Z--\n\n", "\n", "\r\n")
+	directory := t.TempDir()
+	err := os.WriteFile(filepath.Join(directory, "transaction"), []byte(transaction), 0644)
+	if err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+	index := `example.com 192.168.1.10 - - [09/Oct/2018:11:45:38 +0200] "POST / HTTP/1.1" 200 0 "-" "-" W7x4gn8AAQEAAEA1Bv4AAAAC "-" /transaction 0 0 md5:d41d8cd98f00b204e9800998ecf8427e` + "\n"
+	tests := []struct {
+		name     string
+		raw      bool
+		wantRaw  string
+		wantBody []string
+	}{
+		{
+			name:     "Default line endings",
+			wantRaw:  strings.ReplaceAll(transaction, "\r\n", "\n"),
+			wantBody: []string{"a=1"},
+		},
+		{
+			name:     "Raw line endings",
+			raw:      true,
+			wantRaw:  transaction,
+			wantBody: []string{"a=1\r"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := CreateConcurrentRecordReaderFromReader(strings.NewReader(index), "index", directory, false)
+			if err != nil {
+				t.Fatalf("CreateConcurrentRecordReaderFromReader() error = %v", err)
+			}
+			r.SetRawLineEndings(tt.raw)
+			historyBuffer := &strings.Builder{}
+			record, err := r.Next(historyBuffer)
+			if err != nil {
+				t.Fatalf("RecordReader.Next() error = %v", err)
+			}
+			if !reflect.DeepEqual(record.RequestBody, tt.wantBody) {
+				t.Errorf("Record.RequestBody = %q, want %q", record.RequestBody, tt.wantBody)
+			}
+			if historyBuffer.String() != index+tt.wantRaw {
+				t.Errorf("history = %q, want %q", historyBuffer.String(), index+tt.wantRaw)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package modsecure
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"github.com/pkg/errors"
+	"io"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+)
+
+// decompress removes a gzip or bzip2 compression of source. The compression is detected by its magic bytes,
+// sources without a known compression are read as they are.
+func decompress(source io.Reader) (reader io.Reader, compressed bool, err error) {
+	buffered := bufio.NewReader(source)
+	magic, err := buffered.Peek(len(bzip2Magic))
+	if err != nil && err != io.EOF {
+		return nil, false, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		// Concatenated gzip streams, e.g. of "cat a.gz b.gz", are read one after the other.
+		reader, err = gzip.NewReader(buffered)
+		if err != nil {
+			return nil, true, errors.WithMessage(err, "Failed to decompress gzip")
+		}
+		return reader, true, nil
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return bzip2.NewReader(buffered), true, nil
+	}
+	return buffered, false, nil
+}
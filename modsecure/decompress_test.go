@@ -0,0 +1,73 @@
+package modsecure
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCreateRecordReader_Compressed(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		wantIds  []string
+	}{
+		{name: "Plain", filename: "testdata/multiSection/v3_records.txt", wantIds: []string{"Xw5hd7cC", "Yb7sd1aD"}},
+		{name: "gzip", filename: "testdata/compressed/v3_records.txt.gz", wantIds: []string{"Xw5hd7cC", "Yb7sd1aD"}},
+		{name: "bzip2", filename: "testdata/compressed/v3_records.txt.bz2", wantIds: []string{"Xw5hd7cC", "Yb7sd1aD"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := CreateRecordReader(tt.filename, false)
+			if err != nil {
+				t.Errorf("CreateRecordReader() error = %v", err)
+				return
+			}
+			gotIds := make([]string, 0, 2)
+			for record := range r.Iter() {
+				gotIds = append(gotIds, record.Id)
+			}
+			if r.Err != nil {
+				t.Errorf("RecordReader.Iter() error = %v", r.Err)
+			}
+			if !reflect.DeepEqual(gotIds, tt.wantIds) {
+				t.Errorf("RecordReader.Iter() ids = %v, want %v", gotIds, tt.wantIds)
+			}
+		})
+	}
+}
+
+func Test_decompress(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    string
+		wantErr bool
+	}{
+		{name: "Empty", source: "", want: ""},
+		{name: "Short plain text", source: "a", want: "a"},
+		{name: "gzip", source: gzipString("--26bc3c6f-A--"), want: "--26bc3c6f-A--"},
+		{name: "Broken gzip header", source: "\x1f\x8b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, _, err := decompress(strings.NewReader(tt.source))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decompress() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Errorf("decompress() read error = %v", err)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("decompress() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
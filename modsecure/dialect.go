@@ -0,0 +1,86 @@
+package modsecure
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"regexp"
+	"strings"
+)
+
+// EDialect is the flavour of the serial audit log format.
+type EDialect int
+
+const (
+	// DialectAutodetect accepts both dialects and keeps the one of the first section definition read.
+	DialectAutodetect EDialect = iota
+	// DialectV2 is written by ModSecurity 2.x, e.g. "--26bc3c6f-A--".
+	DialectV2
+	// DialectV3 is written by libmodsecurity 3, e.g. "---Xw5hd7cC---A--".
+	DialectV3
+)
+
+var (
+	dialectNames = map[EDialect]string{
+		DialectAutodetect: "auto",
+		DialectV2:         "v2",
+		DialectV3:         "v3",
+	}
+	// parses: "---Xw5hd7cC---A--"
+	sectionStartV3Regex = regexp.MustCompile(`^---([a-zA-Z0-9]{8})---([ABCDEFGHIJKZ])--$`)
+	// parses: "[21/Jan/2020:19:15:36.102934 -0500] 157963413623.140591 172.17.0.1 36402 172.17.0.2 80"
+	logHeaderV3Regex = regexp.MustCompile(`^\[([0-9]{2}/(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)/[0-9]{4}(?::[0-9]{2}){3}(?:\.[0-9]+)?\s[+-][0-9]{4})\]\s([0-9]+(?:\.[0-9]+)?)\s([0-9a-fA-F:.]+)\s([0-9]+)\s([0-9a-fA-F:.]+)\s([0-9]+)$`)
+)
+
+// ParseDialect accepts the names "auto", "v2" and "v3".
+func ParseDialect(value string) (dialect EDialect, err error) {
+	for key, name := range dialectNames {
+		if strings.EqualFold(name, value) {
+			return key, nil
+		}
+	}
+	return DialectAutodetect, errors.New(fmt.Sprintf("Invalid dialect: %s", value))
+}
+
+func (d EDialect) String() string {
+	return dialectNames[d]
+}
+
+func (d EDialect) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *EDialect) UnmarshalText(text []byte) (err error) {
+	*d, err = ParseDialect(string(text))
+	return err
+}
+
+// parseSectionDefinitionDialect parses a section definition of the given dialect. With DialectAutodetect both
+// dialects are accepted, detected tells which one matched.
+func parseSectionDefinitionDialect(line string, dialect EDialect) (success bool, sectionName string, sectionType EStructure, detected EDialect) {
+	if dialect != DialectV3 {
+		if match := sectionStartRegex.FindStringSubmatch(line); match != nil {
+			return true, match[1], keyToEStructure[[]rune(match[2])[0]], DialectV2
+		}
+	}
+	if dialect != DialectV2 {
+		if match := sectionStartV3Regex.FindStringSubmatch(line); match != nil {
+			return true, match[1], keyToEStructure[[]rune(match[2])[0]], DialectV3
+		}
+	}
+	return false, "", NIL, dialect
+}
+
+// parseSectionDefinition parses a section definition in the dialect of the reader. An autodetecting reader
+// keeps the dialect of the first section definition it finds.
+func (r *readBuffer) parseSectionDefinition(line string) (success bool, sectionName string, sectionType EStructure) {
+	success, sectionName, sectionType, detected := parseSectionDefinitionDialect(line, r.Dialect)
+	if success && r.Dialect == DialectAutodetect {
+		r.Dialect = detected
+	}
+	return success, sectionName, sectionType
+}
+
+func (r *readBuffer) isSectionDefinition(line string) (success bool) {
+	success, _, _, _ = parseSectionDefinitionDialect(line, r.Dialect)
+	return success
+}
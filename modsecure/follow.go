@@ -0,0 +1,189 @@
+package modsecure
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFollowPollInterval = 250 * time.Millisecond
+)
+
+// CreateFollowRecordReader reads an audit log while ModSecurity appends to it, like "tail -F". A record is
+// returned as soon as its footer is written, a half-written record is waited for. The reader survives log
+// rotation by renaming (e.g. logrotate with "create") and truncation (e.g. logrotate with "copytruncate").
+// fromEnd skips the records which are already in the file. Reading ends with Close only.
+func CreateFollowRecordReader(filename string, debugSkipper bool, fromEnd bool) (reader *RecordReader, err error) {
+	file, err := os.OpenFile(filename, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	follower := &followReader{
+		filename:     filename,
+		file:         file,
+		pollInterval: defaultFollowPollInterval,
+		mutex:        &sync.Mutex{},
+	}
+	if fromEnd {
+		follower.offset, err = file.Seek(0, io.SeekEnd)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		follower.total = follower.offset
+	}
+	buffer := newReadBuffer(follower, filename, debugSkipper)
+	buffer.Follow = true
+	buffer.closer = follower
+	buffer.seekable = follower
+	buffer.readOffset = follower.offset
+	reader = createRecordReader(buffer)
+	follower.done = reader.done
+	return reader, nil
+}
+
+// followReader reads a file which is still written. At the end of the file it waits for more data instead of
+// returning io.EOF, until done is closed.
+type followReader struct {
+	filename string
+	file     *os.File
+	offset   int64
+	// total counts the bytes read from all files, fileStart is total at the start of the current file.
+	total     int64
+	fileStart int64
+	// lines counts the lines read from all files like Position.Line, fileStartLine is lines at the start of the
+	// current file.
+	lines         int
+	fileStartLine int
+	pollInterval time.Duration
+	done         <-chan struct{}
+	// mutex protects file against Close while the file is switched after a rotation.
+	mutex  *sync.Mutex
+	closed bool
+}
+
+func (f *followReader) Read(p []byte) (n int, err error) {
+	for {
+		f.mutex.Lock()
+		if f.closed {
+			f.mutex.Unlock()
+			return 0, io.EOF
+		}
+		n, err = f.file.Read(p)
+		f.offset += int64(n)
+		f.total += int64(n)
+		f.lines += bytes.Count(p[:n], []byte{'\n'})
+		if n > 0 {
+			f.mutex.Unlock()
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			f.mutex.Unlock()
+			return 0, err
+		}
+		moved, err := f.checkRotation()
+		f.mutex.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		if moved {
+			continue
+		}
+		select {
+		case <-f.done:
+			return 0, io.EOF
+		case <-time.After(f.pollInterval):
+		}
+	}
+}
+
+// checkRotation is called at the end of the file. It reopens a file which was rotated by renaming and starts
+// from the beginning of a truncated file. moved tells whether there may be new data to read.
+func (f *followReader) checkRotation() (moved bool, err error) {
+	current, err := f.file.Stat()
+	if err != nil {
+		return false, err
+	}
+	if current.Size() < f.offset {
+		// Truncated, e.g. by "copytruncate".
+		f.offset, err = f.file.Seek(0, io.SeekStart)
+		f.fileStart = f.total
+		f.fileStartLine = f.lines
+		return true, err
+	}
+	if current.Size() > f.offset {
+		// Written between the read and the check.
+		return true, nil
+	}
+	renamed, err := os.Stat(f.filename)
+	if os.IsNotExist(err) {
+		// Rotated, but the new file is not created yet.
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if os.SameFile(current, renamed) {
+		return false, nil
+	}
+	// Rotated by renaming, the old file is read to its end.
+	file, err := os.OpenFile(f.filename, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return false, err
+	}
+	f.file.Close()
+	f.file = file
+	f.offset = 0
+	f.fileStart = f.total
+	f.fileStartLine = f.lines
+	return true, nil
+}
+
+// Seek is used by SkipTo only. A file which got shorter than offset was rotated, it is read from its beginning.
+func (f *followReader) Seek(offset int64, whence int) (position int64, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if whence != io.SeekStart {
+		return f.offset, errors.New("Only io.SeekStart is supported")
+	}
+	info, err := f.file.Stat()
+	if err != nil {
+		return f.offset, err
+	}
+	if info.Size() < offset {
+		offset = 0
+	}
+	f.offset, err = f.file.Seek(offset, io.SeekStart)
+	f.total = f.offset
+	f.fileStart = 0
+	f.fileStartLine = 0
+	return f.offset, err
+}
+
+// startAtLine sets the number of lines before the position SkipTo seeked to.
+func (f *followReader) startAtLine(line int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.lines = line
+}
+
+// startOfFile returns the position of the start of the current file in the read bytes and lines.
+func (f *followReader) startOfFile() Position {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return Position{Offset: f.fileStart, Line: f.fileStartLine}
+}
+
+func (f *followReader) Close() (err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	return f.file.Close()
+}
@@ -0,0 +1,169 @@
+package modsecure
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func followTestRecord(boundary string) string {
+	return fmt.Sprintf("--%s-A--\n"+
+		"[09/Oct/2018:11:45:38 +0200] W7x4gn8AAQEAAEA1Bv4AAAAA 192.168.1.10 50314 192.168.1.1 80\n"+
+		"--%s-B--\n"+
+		"GET / HTTP/1.1\n"+
+		"Host: example.com\n"+
+		"\n"+
+		"--%s-F--\n"+
+		"HTTP/1.1 200 OK\n"+
+		"\n"+
+		"--%s-Z--\n"+
+		"\n", boundary, boundary, boundary, boundary)
+}
+
+func appendTestFile(t *testing.T, filename string, payload string) {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("os.OpenFile() error = %v", err)
+	}
+	defer file.Close()
+	if _, err = file.WriteString(payload); err != nil {
+		t.Fatalf("File.WriteString() error = %v", err)
+	}
+}
+
+func expectFollowedRecord(t *testing.T, ch <-chan *Record, wantId string) {
+	select {
+	case record, ok := <-ch:
+		if !ok {
+			t.Fatalf("RecordReader.Iter() stopped, want record %s", wantId)
+		}
+		if record.Id != wantId {
+			t.Fatalf("RecordReader.Iter() = %s, want %s", record.Id, wantId)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("RecordReader.Iter() did not return record %s", wantId)
+	}
+}
+
+func TestCreateFollowRecordReader(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "modsec_audit.log")
+	appendTestFile(t, filename, followTestRecord("00000001"))
+
+	r, err := CreateFollowRecordReader(filename, false, false)
+	if err != nil {
+		t.Fatalf("CreateFollowRecordReader() error = %v", err)
+	}
+	r.buffer.closer.(*followReader).pollInterval = 10 * time.Millisecond
+	ch := r.Iter()
+	expectFollowedRecord(t, ch, "00000001")
+
+	// A half-written record waits for its end.
+	record := followTestRecord("00000002")
+	appendTestFile(t, filename, record[:60])
+	select {
+	case item := <-ch:
+		t.Fatalf("RecordReader.Iter() returned %#v before the record was complete", item)
+	case <-time.After(100 * time.Millisecond):
+	}
+	appendTestFile(t, filename, record[60:])
+	expectFollowedRecord(t, ch, "00000002")
+
+	// Rotation by renaming.
+	if err = os.Rename(filename, filename+".1"); err != nil {
+		t.Fatalf("os.Rename() error = %v", err)
+	}
+	appendTestFile(t, filename+".1", followTestRecord("00000003"))
+	appendTestFile(t, filename, followTestRecord("00000004"))
+	expectFollowedRecord(t, ch, "00000003")
+	expectFollowedRecord(t, ch, "00000004")
+
+	// Rotation by copytruncate.
+	if err = os.Truncate(filename, 0); err != nil {
+		t.Fatalf("os.Truncate() error = %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	appendTestFile(t, filename, followTestRecord("00000005"))
+	expectFollowedRecord(t, ch, "00000005")
+
+	if err = r.Close(); err != nil {
+		t.Errorf("RecordReader.Close() error = %v", err)
+	}
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Errorf("RecordReader.Iter() returned a record after Close")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("RecordReader.Iter() did not stop after Close")
+	}
+	if r.Err != nil {
+		t.Errorf("RecordReader.Err = %v, want nil", r.Err)
+	}
+}
+
+func TestCreateFollowRecordReader_FromEnd(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "modsec_audit.log")
+	appendTestFile(t, filename, followTestRecord("00000001"))
+
+	r, err := CreateFollowRecordReader(filename, false, true)
+	if err != nil {
+		t.Fatalf("CreateFollowRecordReader() error = %v", err)
+	}
+	defer r.Close()
+	r.buffer.closer.(*followReader).pollInterval = 10 * time.Millisecond
+	ch := r.Iter()
+	appendTestFile(t, filename, followTestRecord("00000002"))
+	expectFollowedRecord(t, ch, "00000002")
+}
+
+func TestCreateFollowRecordReader_RotationPosition(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "modsec_audit.log")
+	appendTestFile(t, filename, followTestRecord("00000001")+followTestRecord("00000002"))
+
+	r, err := CreateFollowRecordReader(filename, false, false)
+	if err != nil {
+		t.Fatalf("CreateFollowRecordReader() error = %v", err)
+	}
+	defer r.Close()
+	r.buffer.closer.(*followReader).pollInterval = 10 * time.Millisecond
+	ch := r.Iter()
+	record := followTestRecord("00000003")
+	want := Position{Offset: int64(len(record)), Line: strings.Count(record, "\n")}
+	next := func(wantId string) *Record {
+		select {
+		case got, ok := <-ch:
+			if !ok || got.Id != wantId {
+				t.Fatalf("RecordReader.Iter() = %v, want record %s", got, wantId)
+			}
+			return got
+		case <-time.After(5 * time.Second):
+			t.Fatalf("RecordReader.Iter() did not return record %s", wantId)
+		}
+		return nil
+	}
+	next("00000001")
+	next("00000002")
+
+	// The position after a rotation refers to the new file, the lines are counted from its start as well.
+	if err = os.Rename(filename, filename+".1"); err != nil {
+		t.Fatalf("os.Rename() error = %v", err)
+	}
+	appendTestFile(t, filename, record)
+	if got := next("00000003").ResumePosition; got != want {
+		t.Errorf("Record.ResumePosition after renaming = %v, want %v", got, want)
+	}
+	if err = os.Truncate(filename, 0); err != nil {
+		t.Fatalf("os.Truncate() error = %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	appendTestFile(t, filename, followTestRecord("00000004"))
+	if got := next("00000004").ResumePosition; got != want {
+		t.Errorf("Record.ResumePosition after truncation = %v, want %v", got, want)
+	}
+}
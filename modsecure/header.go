@@ -0,0 +1,60 @@
+package modsecure
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Header keeps the header fields of a request or response in the order they were logged.
+// Repeated fields are kept as separate entries.
+type Header []HeaderField
+
+//+k8s:openapi-gen=true
+type HeaderField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Add appends a header field.
+func (h *Header) Add(name, value string) {
+	*h = append(*h, HeaderField{Name: name, Value: value})
+}
+
+// Get returns the value of the first field with the given name, the name is case insensitive.
+func (h Header) Get(name string) string {
+	for _, field := range h {
+		if strings.EqualFold(field.Name, name) {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+// Values returns the values of all fields with the given name in their logged order.
+func (h Header) Values(name string) (values []string) {
+	for _, field := range h {
+		if strings.EqualFold(field.Name, name) {
+			values = append(values, field.Value)
+		}
+	}
+	return values
+}
+
+// Has tells whether a field with the given name exists.
+func (h Header) Has(name string) bool {
+	for _, field := range h {
+		if strings.EqualFold(field.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTPHeader converts to a http.Header. Names are canonicalized, values of repeated fields keep their order.
+func (h Header) HTTPHeader() http.Header {
+	header := make(http.Header, len(h))
+	for _, field := range h {
+		header.Add(field.Name, field.Value)
+	}
+	return header
+}
@@ -0,0 +1,76 @@
+package modsecure
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func testHeader() Header {
+	return Header{
+		{Name: "Host", Value: "example.org"},
+		{Name: "Set-Cookie", Value: "a=1"},
+		{Name: "set-cookie", Value: "b=2"},
+		{Name: "Host", Value: "evil.org"},
+	}
+}
+
+func TestHeader_Get(t *testing.T) {
+	tests := []struct {
+		name      string
+		field     string
+		wantValue string
+	}{
+		{name: "First of repeated field", field: "Host", wantValue: "example.org"},
+		{name: "Case insensitive", field: "SET-COOKIE", wantValue: "a=1"},
+		{name: "Missing field", field: "Content-Length", wantValue: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotValue := testHeader().Get(tt.field); gotValue != tt.wantValue {
+				t.Errorf("Header.Get() = %v, want %v", gotValue, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestHeader_Values(t *testing.T) {
+	tests := []struct {
+		name       string
+		field      string
+		wantValues []string
+	}{
+		{name: "Repeated field", field: "set-cookie", wantValues: []string{"a=1", "b=2"}},
+		{name: "Missing field", field: "Content-Length", wantValues: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotValues := testHeader().Values(tt.field); !reflect.DeepEqual(gotValues, tt.wantValues) {
+				t.Errorf("Header.Values() = %v, want %v", gotValues, tt.wantValues)
+			}
+		})
+	}
+}
+
+func TestHeader_HTTPHeader(t *testing.T) {
+	want := http.Header{
+		"Host":       {"example.org", "evil.org"},
+		"Set-Cookie": {"a=1", "b=2"},
+	}
+	if got := testHeader().HTTPHeader(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Header.HTTPHeader() = %v, want %v", got, want)
+	}
+}
+
+func TestHeader_MarshalJSON(t *testing.T) {
+	want := `[{"name":"Host","value":"example.org"},{"name":"Set-Cookie","value":"a=1"},{"name":"set-cookie","value":"b=2"},{"name":"Host","value":"evil.org"}]`
+	got, err := json.Marshal(testHeader())
+	if err != nil {
+		t.Errorf("json.Marshal(Header) error = %v", err)
+		return
+	}
+	if string(got) != want {
+		t.Errorf("json.Marshal(Header) = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,77 @@
+package modsecure
+
+import (
+	"iter"
+	"strings"
+)
+
+// ParseFailure is a record which could not be parsed, see AllLossy.
+type ParseFailure struct {
+	// Err tells why the record could not be parsed, usually it is a *ParseError.
+	Err error
+	// Raw is the text of the broken record.
+	Raw string
+	// ResumePosition is the position after Raw.
+	ResumePosition Position
+}
+
+func (f *ParseFailure) Error() string {
+	return f.Err.Error()
+}
+
+func (f *ParseFailure) Unwrap() error {
+	return f.Err
+}
+
+// All returns all records of the reader for a range loop. The iteration stops after the first error, which is
+// yielded with a nil record. Unlike Iter no goroutine is started, the records are read while the loop runs.
+// The reader is closed when the iteration ends, also when the loop is left early.
+func (r *RecordReader) All() iter.Seq2[*Record, error] {
+	return func(yield func(*Record, error) bool) {
+		defer r.Close()
+		historyBuffer := &strings.Builder{}
+		for r.HasNext() {
+			record, err := r.Next(historyBuffer)
+			historyBuffer.Reset()
+			if err == ErrEndReached || (err != nil && r.isClosed()) {
+				// A record cut off by Close is not broken.
+				return
+			}
+			if !yield(record, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// AllLossy is All, which continues after records which could not be parsed. They are yielded as a
+// *ParseFailure with a nil record.
+func (r *RecordReader) AllLossy() iter.Seq2[*Record, *ParseFailure] {
+	return func(yield func(*Record, *ParseFailure) bool) {
+		defer r.Close()
+		historyBuffer := &strings.Builder{}
+		for r.HasNext() {
+			record, err := r.Next(historyBuffer)
+			if err == ErrEndReached || (err != nil && r.isClosed()) {
+				return
+			}
+			if err != nil {
+				r.PeekToNextValidStart(historyBuffer)
+				failure := &ParseFailure{
+					Err:            err,
+					Raw:            historyBuffer.String(),
+					ResumePosition: r.Position(),
+				}
+				historyBuffer.Reset()
+				if !yield(nil, failure) {
+					return
+				}
+				continue
+			}
+			historyBuffer.Reset()
+			if !yield(record, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package modsecure
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRecordReader_All(t *testing.T) {
+	tests := []struct {
+		name         string
+		count        int
+		brokenEvery  int
+		stopAfter    int
+		wantRecords  int
+		wantCategory EErrorCategory
+	}{
+		{
+			name:        "All records",
+			count:       5,
+			wantRecords: 5,
+		},
+		{
+			name:         "Stops at the first error",
+			count:        5,
+			brokenEvery:  3,
+			wantRecords:  2,
+			wantCategory: CategoryRequestLine,
+		},
+		{
+			name:        "Loop left early",
+			count:       5,
+			stopAfter:   1,
+			wantRecords: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := CreateRecordReader(writeTestLog(t, tt.count, tt.brokenEvery), false)
+			if err != nil {
+				t.Fatalf("CreateRecordReader() error = %v", err)
+			}
+			records := 0
+			var gotErr error
+			for record, err := range r.All() {
+				if err != nil {
+					if record != nil || gotErr != nil {
+						t.Errorf("RecordReader.All() yielded %v, %v after error %v", record, err, gotErr)
+					}
+					gotErr = err
+					continue
+				}
+				records++
+				if records == tt.stopAfter {
+					break
+				}
+			}
+			if records != tt.wantRecords {
+				t.Errorf("RecordReader.All() yielded %d records, want %d", records, tt.wantRecords)
+			}
+			if (gotErr != nil) != (tt.wantCategory != CategoryUnknown) || (gotErr != nil && !errors.Is(gotErr, tt.wantCategory)) {
+				t.Errorf("RecordReader.All() error = %v, want %v", gotErr, tt.wantCategory)
+			}
+			if !r.isClosed() {
+				t.Errorf("RecordReader is not closed after the iteration")
+			}
+		})
+	}
+}
+
+func TestRecordReader_AllLossy(t *testing.T) {
+	filename := writeTestLog(t, 10, 3)
+	r, err := CreateRecordReader(filename, false)
+	if err != nil {
+		t.Fatalf("CreateRecordReader() error = %v", err)
+	}
+	i := 0
+	for record, failure := range r.AllLossy() {
+		broken := i%3 == 2
+		switch {
+		case broken && (record != nil || failure == nil):
+			t.Errorf("Record %d: RecordReader.AllLossy() = %v, %v, want a failure", i, record, failure)
+		case broken && !strings.Contains(failure.Raw, "broken request line"):
+			t.Errorf("Record %d: ParseFailure.Raw = %q, want the broken record", i, failure.Raw)
+		case broken && !errors.Is(failure, CategoryRequestLine):
+			t.Errorf("Record %d: ParseFailure.Err = %v, want %v", i, failure.Err, CategoryRequestLine)
+		case !broken && (record == nil || failure != nil):
+			t.Errorf("Record %d: RecordReader.AllLossy() = %v, %v, want a record", i, record, failure)
+		}
+		i++
+	}
+	if i != 10 {
+		t.Errorf("RecordReader.AllLossy() yielded %d items, want 10", i)
+	}
+
+	// IterLossy sends every broken record once.
+	r, err = CreateRecordReader(filename, false)
+	if err != nil {
+		t.Fatalf("CreateRecordReader() error = %v", err)
+	}
+	items := collectRecordAndRaws(r.IterLossy())
+	if len(items) != 10 {
+		t.Fatalf("RecordReader.IterLossy() returned %d items, want 10", len(items))
+	}
+	if items[2].Record != nil || items[2].Err == nil || !strings.Contains(items[2].Raw, "broken request line") {
+		t.Errorf("RecordReader.IterLossy() = %+v, want the broken record", items[2])
+	}
+}
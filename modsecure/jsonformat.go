@@ -0,0 +1,550 @@
+package modsecure
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EFormat is the format of an audit log file.
+type EFormat int
+
+const (
+	// FormatSerial is the boundary delimited format ("--26bc3c6f-A--").
+	FormatSerial EFormat = iota
+	// FormatJSON is written with "SecAuditLogFormat JSON", one transaction per line.
+	FormatJSON
+)
+
+var (
+	formatNames = map[EFormat]string{
+		FormatSerial: "serial",
+		FormatJSON:   "json",
+	}
+	// "Tue Jan 21 19:15:36 2020", used by libmodsecurity 3
+	layoutDateV3JSON = "Mon Jan _2 15:04:05 2006"
+)
+
+// ParseFormat accepts the names "serial" and "json".
+func ParseFormat(value string) (format EFormat, err error) {
+	for key, name := range formatNames {
+		if strings.EqualFold(name, value) {
+			return key, nil
+		}
+	}
+	return FormatSerial, errors.New(fmt.Sprintf("Invalid format: %s", value))
+}
+
+func (f EFormat) String() string {
+	return formatNames[f]
+}
+
+// jsonTransaction holds the fields of "transaction" of both layouts. ModSecurity 2 puts the other parts of
+// the record next to it, libmodsecurity 3 puts them into it.
+type jsonTransaction struct {
+	// ModSecurity 2
+	Time          string `json:"time"`
+	TransactionID string `json:"transaction_id"`
+	RemoteAddress string `json:"remote_address"`
+	RemotePort    uint16 `json:"remote_port"`
+	LocalAddress  string `json:"local_address"`
+	LocalPort     uint16 `json:"local_port"`
+	// libmodsecurity 3
+	ClientIP   string               `json:"client_ip"`
+	TimeStamp  string               `json:"time_stamp"`
+	ClientPort uint16               `json:"client_port"`
+	HostIP     string               `json:"host_ip"`
+	HostPort   uint16               `json:"host_port"`
+	UniqueID   string               `json:"unique_id"`
+	Request    *jsonRequest         `json:"request"`
+	Response   *jsonResponse        `json:"response"`
+	Producer   *jsonV3Producer      `json:"producer"`
+	Messages   []*jsonV3RuleMessage `json:"messages"`
+}
+
+type jsonRequest struct {
+	// ModSecurity 2
+	RequestLine string `json:"request_line"`
+	// libmodsecurity 3
+	Method      string      `json:"method"`
+	HTTPVersion json.Number `json:"http_version"`
+	URI         string      `json:"uri"`
+	// both
+	Headers json.RawMessage `json:"headers"`
+	Body    json.RawMessage `json:"body"`
+}
+
+type jsonResponse struct {
+	// ModSecurity 2
+	Protocol string `json:"protocol"`
+	Status   uint16 `json:"status"`
+	// libmodsecurity 3
+	HTTPCode uint16 `json:"http_code"`
+	// both
+	Headers json.RawMessage `json:"headers"`
+	Body    json.RawMessage `json:"body"`
+}
+
+type jsonV2AuditData struct {
+	Messages              []string         `json:"messages"`
+	ErrorMessages         []string         `json:"error_messages"`
+	Handler               string           `json:"handler"`
+	Stopwatch             map[string]int64 `json:"stopwatch"`
+	ResponseBodyDechunked bool             `json:"response_body_dechunked"`
+	Producer              []string         `json:"producer"`
+	Server                string           `json:"server"`
+	EngineMode            string           `json:"engine_mode"`
+	Action                *struct {
+		Intercepted bool `json:"intercepted"`
+		Phase       int  `json:"phase"`
+	} `json:"action"`
+}
+
+type jsonV2MatchedRule struct {
+	Chain bool `json:"chain"`
+	Rules []struct {
+		Unparsed  string `json:"unparsed"`
+		IsMatched bool   `json:"is_matched"`
+	} `json:"rules"`
+}
+
+type jsonV2Uploads struct {
+	Info []struct {
+		FileSize    uint64 `json:"file_size"`
+		FileName    string `json:"file_name"`
+		ContentType string `json:"content_type"`
+	} `json:"info"`
+	Total uint64 `json:"total"`
+}
+
+type jsonV3Producer struct {
+	ModSecurity    string   `json:"modsecurity"`
+	Connector      string   `json:"connector"`
+	SecRulesEngine string   `json:"secrules_engine"`
+	Components     []string `json:"components"`
+}
+
+type jsonV3RuleMessage struct {
+	Message string `json:"message"`
+	Details struct {
+		Match      string   `json:"match"`
+		Reference  string   `json:"reference"`
+		RuleID     string   `json:"ruleId"`
+		File       string   `json:"file"`
+		LineNumber string   `json:"lineNumber"`
+		Data       string   `json:"data"`
+		Severity   string   `json:"severity"`
+		Ver        string   `json:"ver"`
+		Rev        string   `json:"rev"`
+		Tags       []string `json:"tags"`
+		Maturity   string   `json:"maturity"`
+		Accuracy   string   `json:"accuracy"`
+	} `json:"details"`
+}
+
+type jsonRecord struct {
+	Transaction  *jsonTransaction    `json:"transaction"`
+	Request      *jsonRequest        `json:"request"`
+	Response     *jsonResponse       `json:"response"`
+	AuditData    *jsonV2AuditData    `json:"audit_data"`
+	MatchedRules []jsonV2MatchedRule `json:"matched_rules"`
+	Uploads      *jsonV2Uploads      `json:"uploads"`
+}
+
+// ReadSingleJSONRecord reads the next line holding a JSON record. Empty lines are skipped.
+func ReadSingleJSONRecord(reader *readBuffer, historyBuffer *strings.Builder) (record *Record, err error) {
+	reader.readRecordMutex.Lock()
+	defer reader.readRecordMutex.Unlock()
+	reader.startRecord()
+	for {
+		start := reader.position()
+		line, err := reader.ReadLine()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if err == io.EOF {
+			reader.IsFinished = true
+			if strings.TrimSpace(line) == "" {
+				return nil, ErrEndReached
+			}
+		}
+		reader.writeLine(historyBuffer, line)
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(reader.truncations) > 0 {
+			// A cut JSON record can not be parsed.
+			return nil, reader.newParseError(errors.WithMessage(&LimitError{Truncation: *reader.truncations[0]}, fmt.Sprintf("Error in line: %d", reader.linePointer)),
+				NIL, CategoryJSON, start, line)
+		}
+		record, err = parseJSONRecord([]byte(line))
+		if err != nil {
+			return nil, reader.newParseError(errors.WithMessage(err, fmt.Sprintf("Error in line: %d", reader.linePointer)),
+				NIL, CategoryJSON, start, line)
+		}
+		record.RecordLine = reader.linePointer
+		if reader.DecodeResponseBodies {
+			record.decodeResponseBodies()
+		}
+		skipEmptyLines(reader, historyBuffer)
+		return record, nil
+	}
+}
+
+func parseJSONRecord(payload []byte) (record *Record, err error) {
+	parsed := &jsonRecord{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	err = decoder.Decode(parsed)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Invalid JSON record")
+	}
+	if parsed.Transaction == nil {
+		return nil, errors.New("Invalid JSON record, transaction is missing")
+	}
+	if parsed.Transaction.ClientIP != "" || parsed.Transaction.UniqueID != "" {
+		return parseJSONV3Record(parsed.Transaction)
+	}
+	return parseJSONV2Record(parsed)
+}
+
+func parseJSONV2Record(parsed *jsonRecord) (record *Record, err error) {
+	transaction := parsed.Transaction
+	date, err := time.Parse(layoutDate, transaction.Time)
+	if err != nil {
+		return nil, newCategoryError(CategoryTimestamp, fmt.Sprintf("Invalid JSON record, time is broken: %s", transaction.Time))
+	}
+	auditHeader, err := createJSONAuditHeader(date, transaction.TransactionID, transaction.RemoteAddress, transaction.RemotePort, transaction.LocalAddress, transaction.LocalPort)
+	if err != nil {
+		return nil, err
+	}
+	record = &Record{
+		Id:          transaction.TransactionID,
+		AuditHeader: auditHeader,
+		Dialect:     DialectV2,
+	}
+	if parsed.Request != nil {
+		header, err := decodeJSONHeader(parsed.Request.Headers)
+		if err != nil {
+			return nil, err
+		}
+		record.RequestHeader, err = parseRequestHeader([]string{parsed.Request.RequestLine})
+		if err != nil {
+			return nil, errors.WithMessage(err, "Invalid JSON record, request_line is broken")
+		}
+		record.RequestHeader.Header = header
+		record.RequestBody, err = decodeJSONRecordBody(parsed.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+		record.decodeRequestBody("")
+	}
+	if parsed.Response != nil {
+		header, err := decodeJSONHeader(parsed.Response.Headers)
+		if err != nil {
+			return nil, err
+		}
+		record.ResponseHeader = &SectionFResponseHeaders{
+			Protocol: parsed.Response.Protocol,
+			Status:   parsed.Response.Status,
+			Header:   header,
+		}
+		record.ResponseBody, err = decodeJSONRecordBody(parsed.Response.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if parsed.AuditData != nil {
+		record.AuditLogTrailer = createJSONV2Trailer(parsed.AuditData)
+		record.RuleMatches = parseRuleMatches(record.AuditLogTrailer.Messages)
+	}
+	if parsed.MatchedRules != nil {
+		record.MatchedRulesInformation, err = createJSONV2MatchedRules(parsed.MatchedRules)
+		if err != nil {
+			return nil, errors.WithMessage(err, "Invalid JSON record")
+		}
+	}
+	if parsed.Uploads != nil {
+		record.MultipartFilesInformation = &SectionJMultipartFileInformation{
+			Files:     make([]*MultipartFile, 0, len(parsed.Uploads.Info)),
+			TotalSize: parsed.Uploads.Total,
+		}
+		for i, elem := range parsed.Uploads.Info {
+			record.MultipartFilesInformation.Files = append(record.MultipartFilesInformation.Files, &MultipartFile{
+				Index:       i + 1,
+				FileName:    elem.FileName,
+				Size:        elem.FileSize,
+				ContentType: elem.ContentType,
+			})
+		}
+		record.MultipartFilesInformation.addFieldNames(record.DecodedRequestBody)
+	}
+	return record, nil
+}
+
+func createJSONV2Trailer(auditData *jsonV2AuditData) (section *SectionHAuditLogTrailer) {
+	section = &SectionHAuditLogTrailer{
+		Messages:      auditData.Messages,
+		ApacheErrors:  auditData.ErrorMessages,
+		ApacheHandler: auditData.Handler,
+		Server:        auditData.Server,
+		EngineMode:    auditData.EngineMode,
+	}
+	if auditData.Stopwatch != nil {
+		micros := func(name string) time.Duration {
+			return time.Duration(auditData.Stopwatch[name]) * time.Microsecond
+		}
+		section.Stopwatch2 = &Stopwatch2{
+			Combined:          micros("combined"),
+			Phase1:            micros("p1"),
+			Phase2:            micros("p2"),
+			Phase3:            micros("p3"),
+			Phase4:            micros("p4"),
+			Phase5:            micros("p5"),
+			StorageRead:       micros("sr"),
+			StorageWrite:      micros("sw"),
+			Logging:           micros("l"),
+			GarbageCollection: micros("gc"),
+		}
+	}
+	if len(auditData.Producer) > 0 {
+		section.Producer = &Producer{
+			Engine:     auditData.Producer[0],
+			Components: auditData.Producer[1:],
+		}
+	}
+	if auditData.Action != nil && auditData.Action.Intercepted {
+		section.Action = &TrailerAction{
+			Name:  "Intercepted",
+			Phase: auditData.Action.Phase,
+		}
+	}
+	if auditData.ResponseBodyDechunked {
+		section.ResponseBodyTransformed = responseBodyDechunked
+	}
+	return section
+}
+
+func createJSONV2MatchedRules(matchedRules []jsonV2MatchedRule) (section *SectionKMatchedRuleInformation, err error) {
+	section = &SectionKMatchedRuleInformation{
+		Rules: make([]*MatchedRule, 0, len(matchedRules)),
+	}
+	for _, elem := range matchedRules {
+		var parent *MatchedRule
+		for _, unparsed := range elem.Rules {
+			rule, err := parseMatchedRule(unparsed.Unparsed)
+			if err != nil {
+				return nil, err
+			}
+			if parent == nil {
+				parent = rule
+				section.Rules = append(section.Rules, rule)
+				continue
+			}
+			rule.Matched = unparsed.IsMatched
+			parent.Chain = append(parent.Chain, rule)
+		}
+	}
+	return section, nil
+}
+
+func parseJSONV3Record(transaction *jsonTransaction) (record *Record, err error) {
+	date, err := time.Parse(layoutDateV3JSON, transaction.TimeStamp)
+	if err != nil {
+		return nil, newCategoryError(CategoryTimestamp, fmt.Sprintf("Invalid JSON record, time_stamp is broken: %s", transaction.TimeStamp))
+	}
+	auditHeader, err := createJSONAuditHeader(date, transaction.UniqueID, transaction.ClientIP, transaction.ClientPort, transaction.HostIP, transaction.HostPort)
+	if err != nil {
+		return nil, err
+	}
+	record = &Record{
+		Id:          transaction.UniqueID,
+		AuditHeader: auditHeader,
+		Dialect:     DialectV3,
+	}
+	protocol := ""
+	if transaction.Request != nil {
+		header, err := decodeJSONHeader(transaction.Request.Headers)
+		if err != nil {
+			return nil, err
+		}
+		protocol = "HTTP/" + transaction.Request.HTTPVersion.String()
+		record.RequestHeader = &SectionBRequestHeader{
+			Protocol: protocol,
+			Method:   transaction.Request.Method,
+			Path:     transaction.Request.URI,
+			Header:   header,
+		}
+		record.RequestBody, err = decodeJSONRecordBody(transaction.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+		record.decodeRequestBody("")
+	}
+	if transaction.Response != nil {
+		header, err := decodeJSONHeader(transaction.Response.Headers)
+		if err != nil {
+			return nil, err
+		}
+		record.ResponseHeader = &SectionFResponseHeaders{
+			Protocol: protocol,
+			Status:   transaction.Response.HTTPCode,
+			Header:   header,
+		}
+		record.ResponseBody, err = decodeJSONRecordBody(transaction.Response.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if transaction.Producer != nil {
+		record.AuditLogTrailer = &SectionHAuditLogTrailer{
+			Producer: &Producer{
+				Engine:     transaction.Producer.ModSecurity,
+				Components: transaction.Producer.Components,
+			},
+			EngineMode: transaction.Producer.SecRulesEngine,
+		}
+		if transaction.Producer.Connector != "" {
+			record.AuditLogTrailer.Other = map[string][]string{
+				"Connector": {transaction.Producer.Connector},
+			}
+		}
+	}
+	if transaction.Messages != nil {
+		record.RuleMatches = make([]*RuleMatch, 0, len(transaction.Messages))
+		for _, elem := range transaction.Messages {
+			match, err := createJSONV3RuleMatch(elem)
+			if err != nil {
+				return nil, errors.WithMessage(err, "Invalid JSON record")
+			}
+			record.RuleMatches = append(record.RuleMatches, match)
+		}
+	}
+	return record, nil
+}
+
+func createJSONV3RuleMatch(message *jsonV3RuleMessage) (match *RuleMatch, err error) {
+	details := message.Details
+	match = &RuleMatch{
+		Description: details.Match,
+		File:        details.File,
+		ID:          details.RuleID,
+		Rev:         details.Rev,
+		Msg:         message.Message,
+		Data:        details.Data,
+		Severity:    SeverityUnknown,
+		Version:     details.Ver,
+		Tags:        details.Tags,
+	}
+	// libmodsecurity 3 writes the numbers as strings, empty if not set.
+	numbers := []struct {
+		name  string
+		value string
+		field *int
+	}{
+		{"lineNumber", details.LineNumber, &match.Line},
+		{"maturity", details.Maturity, &match.Maturity},
+		{"accuracy", details.Accuracy, &match.Accuracy},
+	}
+	for _, elem := range numbers {
+		if elem.value == "" {
+			continue
+		}
+		*elem.field, err = strconv.Atoi(elem.value)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid message, %s is broken: %s", elem.name, elem.value))
+		}
+	}
+	if details.Severity != "" {
+		match.Severity, err = ParseSeverity(details.Severity)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if details.Reference != "" {
+		match.Other = map[string][]string{
+			"ref": {details.Reference},
+		}
+	}
+	if parsedData := matchedDataRegex.FindStringSubmatch(match.Data); parsedData != nil {
+		match.MatchedData = parsedData[1]
+		match.MatchedVariable = parsedData[2]
+		match.MatchedValue = parsedData[3]
+	}
+	return match, nil
+}
+
+func createJSONAuditHeader(date time.Time, id string, sourceAddress string, sourcePort uint16, destinationAddress string, destinationPort uint16) (section *SectionAAuditHeader, err error) {
+	sourceIp := net.ParseIP(sourceAddress)
+	if sourceIp == nil {
+		return nil, newCategoryError(CategoryIPAddress, fmt.Sprintf("Invalid JSON record, SourceIp is broken: %s", sourceAddress))
+	}
+	destIp := net.ParseIP(destinationAddress)
+	if destIp == nil {
+		return nil, newCategoryError(CategoryIPAddress, fmt.Sprintf("Invalid JSON record, DestIp is broken: %s", destinationAddress))
+	}
+	return &SectionAAuditHeader{
+		Timestamp:       date,
+		TransactionID:   id,
+		SourceIP:        sourceIp,
+		SourcePort:      sourcePort,
+		DestinationIP:   destIp,
+		DestinationPort: destinationPort,
+	}, nil
+}
+
+// decodeJSONHeader decodes a JSON object of headers keeping the order of its keys.
+func decodeJSONHeader(raw json.RawMessage) (header Header, err error) {
+	header = make(Header, 0, 8)
+	if len(raw) == 0 || string(raw) == "null" {
+		return header, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil || token != json.Delim('{') {
+		return nil, errors.New("Invalid JSON record, headers are not an object")
+	}
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return nil, errors.WithMessage(err, "Invalid JSON record, headers are broken")
+		}
+		name := token.(string)
+		var value interface{}
+		err = decoder.Decode(&value)
+		if err != nil {
+			return nil, errors.WithMessage(err, "Invalid JSON record, headers are broken")
+		}
+		header.Add(name, fmt.Sprint(value))
+	}
+	return header, nil
+}
+
+// decodeJSONRecordBody decodes a body written as string or as array of strings into lines.
+func decodeJSONRecordBody(raw json.RawMessage) (lines []string, err error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var body string
+	if raw[0] == '[' {
+		var parts []string
+		err = json.Unmarshal(raw, &parts)
+		body = strings.Join(parts, "")
+	} else {
+		err = json.Unmarshal(raw, &body)
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "Invalid JSON record, body is broken")
+	}
+	if body == "" {
+		return nil, nil
+	}
+	return strings.Split(body, "\n"), nil
+}
@@ -0,0 +1,237 @@
+package modsecure
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordReader_JSONV2Records(t *testing.T) {
+	r, err := CreateJSONRecordReader("testdata/json/v2_records.txt", false)
+	if err != nil {
+		t.Errorf("CreateJSONRecordReader() error = %v", err)
+		return
+	}
+	records := make([]*Record, 0, 2)
+	for record := range r.Iter() {
+		records = append(records, record)
+	}
+	if r.Err != nil {
+		t.Errorf("RecordReader.Iter() error = %v", r.Err)
+		return
+	}
+	if len(records) != 2 {
+		t.Errorf("RecordReader.Iter() returned %d records, want 2", len(records))
+		return
+	}
+	record := records[0]
+	wantHeader := &SectionAAuditHeader{
+		Timestamp:       time.Date(2018, time.October, 9, 11, 45, 38, 0, time.FixedZone("", 2*60*60)),
+		TransactionID:   "W7x4gn8AAQEAAEA1Bv4AAAAA",
+		SourceIP:        net.ParseIP("192.168.1.10"),
+		SourcePort:      50314,
+		DestinationIP:   net.ParseIP("192.168.1.1"),
+		DestinationPort: 80,
+	}
+	if !reflect.DeepEqual(record.AuditHeader, wantHeader) {
+		t.Errorf("Record.AuditHeader =\n is   %#v,\n want %#v", record.AuditHeader, wantHeader)
+	}
+	wantRequest := &SectionBRequestHeader{
+		Protocol: "HTTP/1.1",
+		Method:   "POST",
+		Path:     "/login.php",
+		Header: Header{
+			{Name: "Host", Value: "example.com"},
+			{Name: "Content-Type", Value: "application/x-www-form-urlencoded"},
+			{Name: "Content-Length", Value: "20"},
+		},
+	}
+	if !reflect.DeepEqual(record.RequestHeader, wantRequest) {
+		t.Errorf("Record.RequestHeader =\n is   %#v,\n want %#v", record.RequestHeader, wantRequest)
+	}
+	if record.DecodedRequestBody == nil || len(record.DecodedRequestBody.Form) != 2 {
+		t.Errorf("Record.DecodedRequestBody = %#v, want two form fields", record.DecodedRequestBody)
+	}
+	if record.Dialect != DialectV2 || record.RecordLine != 1 || records[1].RecordLine != 3 {
+		t.Errorf("Record.Dialect = %v, RecordLine = %d, %d", record.Dialect, record.RecordLine, records[1].RecordLine)
+	}
+	if record.ResponseHeader.Status != 403 || record.ResponseHeader.Header.Get("Set-Cookie") != "a=1" {
+		t.Errorf("Record.ResponseHeader = %#v", record.ResponseHeader)
+	}
+	trailer := record.AuditLogTrailer
+	if trailer.Action == nil || trailer.Action.Phase != 2 || trailer.Stopwatch2.Phase2 != 641*time.Microsecond || trailer.Producer.Components[0] != "OWASP_CRS/3.0.2" {
+		t.Errorf("Record.AuditLogTrailer = %#v", trailer)
+	}
+	if len(record.RuleMatches) != 1 || record.RuleMatches[0].ID != "1001" || record.RuleMatches[0].Severity != SeverityCritical {
+		t.Errorf("Record.RuleMatches = %#v", record.RuleMatches)
+	}
+	rules := record.MatchedRulesInformation.Rules
+	if len(rules) != 1 || rules[0].ID != "1001" || len(rules[0].Chain) != 1 || rules[0].Chain[0].Matched {
+		t.Errorf("Record.MatchedRulesInformation = %#v", record.MatchedRulesInformation)
+	}
+	wantFiles := &SectionJMultipartFileInformation{
+		Files:     []*MultipartFile{{Index: 1, FileName: "shell.php", Size: 128, ContentType: "application/x-php"}},
+		TotalSize: 128,
+	}
+	if !reflect.DeepEqual(record.MultipartFilesInformation, wantFiles) {
+		t.Errorf("Record.MultipartFilesInformation =\n is   %#v,\n want %#v", record.MultipartFilesInformation, wantFiles)
+	}
+	if !records[1].AuditHeader.SourceIP.Equal(net.IPv6loopback) || records[1].AuditLogTrailer != nil {
+		t.Errorf("second Record = %#v", records[1])
+	}
+}
+
+func TestRecordReader_JSONV3Record(t *testing.T) {
+	r, err := CreateJSONRecordReader("testdata/json/v3_records.txt", false)
+	if err != nil {
+		t.Errorf("CreateJSONRecordReader() error = %v", err)
+		return
+	}
+	record, err := r.Next(&strings.Builder{})
+	if err != nil {
+		t.Errorf("RecordReader.Next() error = %v", err)
+		return
+	}
+	if r.HasNext() {
+		t.Errorf("RecordReader.HasNext() = true after the last record")
+	}
+	wantHeader := &SectionAAuditHeader{
+		Timestamp:       time.Date(2020, time.January, 21, 19, 15, 36, 0, time.UTC),
+		TransactionID:   "157963413661.219434",
+		SourceIP:        net.ParseIP("200.249.12.31"),
+		SourcePort:      12345,
+		DestinationIP:   net.ParseIP("200.249.12.31"),
+		DestinationPort: 80,
+	}
+	if !reflect.DeepEqual(record.AuditHeader, wantHeader) {
+		t.Errorf("Record.AuditHeader =\n is   %#v,\n want %#v", record.AuditHeader, wantHeader)
+	}
+	if record.Id != "157963413661.219434" || record.Dialect != DialectV3 {
+		t.Errorf("Record.Id = %s, Dialect = %v", record.Id, record.Dialect)
+	}
+	if record.RequestHeader.Protocol != "HTTP/1.1" || record.RequestHeader.Path != "/test.pl?param1=test&para2=test2" || record.RequestBody != nil {
+		t.Errorf("Record.RequestHeader = %#v, RequestBody = %#v", record.RequestHeader, record.RequestBody)
+	}
+	if !reflect.DeepEqual(record.ResponseBody, []string{"no need."}) {
+		t.Errorf("Record.ResponseBody = %#v", record.ResponseBody)
+	}
+	if record.AuditLogTrailer.Producer.Engine != "ModSecurity v3.0.4 (Linux)" || record.AuditLogTrailer.Other["Connector"][0] != "ModSecurity-nginx v1.0.1" {
+		t.Errorf("Record.AuditLogTrailer = %#v", record.AuditLogTrailer)
+	}
+	wantMatch := &RuleMatch{
+		Description:     "Matched \"Operator `PmFromFile' with parameter `scanners-user-agents.data' against variable `REQUEST_HEADERS:User-Agent' (Value: `curl/7.38.0' )",
+		File:            "/etc/modsecurity/rules/REQUEST-913-SCANNER-DETECTION.conf",
+		Line:            33,
+		ID:              "913100",
+		Msg:             "Found User-Agent associated with security scanner",
+		Data:            "Matched Data: curl found within REQUEST_HEADERS:User-Agent: curl/7.38.0",
+		Severity:        SeverityCritical,
+		Version:         "OWASP_CRS/3.2.0",
+		Tags:            []string{"application-multi", "attack-reputation-scanner"},
+		MatchedData:     "curl",
+		MatchedVariable: "REQUEST_HEADERS:User-Agent",
+		MatchedValue:    "curl/7.38.0",
+		Other:           map[string][]string{"ref": {"o0,4v55,11t:lowercase"}},
+	}
+	if len(record.RuleMatches) != 1 || !reflect.DeepEqual(record.RuleMatches[0], wantMatch) {
+		t.Errorf("Record.RuleMatches =\n is   %#v,\n want %#v", record.RuleMatches, wantMatch)
+	}
+}
+
+func TestRecordReader_JSONDecodeResponseBodies(t *testing.T) {
+	line := `{"transaction":{"client_ip":"200.249.12.31","time_stamp":"Tue Jan 21 19:15:36 2020","client_port":12345,` +
+		`"host_ip":"200.249.12.31","host_port":80,"unique_id":"157963413661.219434",` +
+		`"request":{"method":"GET","http_version":1.1,"uri":"/","headers":{"Host":"localhost"}},` +
+		`"response":{"http_code":200,"headers":{"Transfer-Encoding":"chunked"},"body":"5\r\nhello\r\n0\r\n\r\n"}}}` + "\n"
+	r, err := CreateRecordReaderFromReader(strings.NewReader(line), "test", false)
+	if err != nil {
+		t.Fatalf("CreateRecordReaderFromReader() error = %v", err)
+	}
+	r.SetFormat(FormatJSON)
+	r.SetDecodeResponseBodies(true)
+	record, err := r.Next(&strings.Builder{})
+	if err != nil {
+		t.Fatalf("RecordReader.Next() error = %v", err)
+	}
+	want := &DecodedResponseBody{Body: "hello", Decoded: true}
+	if !reflect.DeepEqual(record.DecodedResponseBody, want) {
+		t.Errorf("Record.DecodedResponseBody = %#v, want %#v", record.DecodedResponseBody, want)
+	}
+}
+
+func Test_parseJSONRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		wantErr bool
+	}{
+		{name: "No JSON", payload: `--26bc3c6f-A--`, wantErr: true},
+		{name: "Missing transaction", payload: `{"request":{}}`, wantErr: true},
+		{name: "Broken time", payload: `{"transaction":{"time":"yesterday","remote_address":"::1","local_address":"::1"}}`, wantErr: true},
+		{name: "Broken address", payload: `{"transaction":{"time":"09/Oct/2018:11:45:38 +0200","remote_address":"nope","local_address":"::1"}}`, wantErr: true},
+		{name: "Broken headers", payload: `{"transaction":{"client_ip":"::1","time_stamp":"Tue Jan 21 19:15:36 2020","host_ip":"::1","request":{"headers":[]}}}`, wantErr: true},
+		{name: "Minimal v2 record", payload: `{"transaction":{"time":"09/Oct/2018:11:45:38 +0200","transaction_id":"a","remote_address":"::1","local_address":"::1"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseJSONRecord([]byte(tt.payload))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseJSONRecord() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_decodeJSONHeader(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        string
+		wantHeader Header
+		wantErr    bool
+	}{
+		{name: "Missing", raw: ``, wantHeader: Header{}},
+		{name: "Keeps order", raw: `{"b":"1","a":"2","c":3}`, wantHeader: Header{{Name: "b", Value: "1"}, {Name: "a", Value: "2"}, {Name: "c", Value: "3"}}},
+		{name: "No object", raw: `["a"]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotHeader, err := decodeJSONHeader(json.RawMessage(tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeJSONHeader() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(gotHeader, tt.wantHeader) {
+				t.Errorf("decodeJSONHeader() = %#v, want %#v", gotHeader, tt.wantHeader)
+			}
+		})
+	}
+}
+
+func Test_decodeJSONRecordBody(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		wantLines []string
+		wantErr   bool
+	}{
+		{name: "Missing", raw: `null`, wantLines: nil},
+		{name: "String", raw: `"a\nb"`, wantLines: []string{"a", "b"}},
+		{name: "Array of parts", raw: `["a\n", "b"]`, wantLines: []string{"a", "b"}},
+		{name: "Number", raw: `1`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotLines, err := decodeJSONRecordBody(json.RawMessage(tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeJSONRecordBody() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotLines, tt.wantLines) {
+				t.Errorf("decodeJSONRecordBody() = %#v, want %#v", gotLines, tt.wantLines)
+			}
+		})
+	}
+}
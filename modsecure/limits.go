@@ -0,0 +1,157 @@
+package modsecure
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// ELimit names a limit of Limits.
+type ELimit int
+
+const (
+	LimitLine ELimit = iota
+	LimitSection
+	LimitRecord
+)
+
+var (
+	limitNames = map[ELimit]string{
+		LimitLine:    "line",
+		LimitSection: "section",
+		LimitRecord:  "record",
+	}
+)
+
+func (l ELimit) String() string {
+	return limitNames[l]
+}
+
+func (l ELimit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// Limits bounds the memory used by a single record. A zero size is no limit.
+type Limits struct {
+	// MaxLineSize is the maximal size of a line without its line break.
+	MaxLineSize int
+	// MaxSectionSize is the maximal size of the body of a section, including line breaks.
+	MaxSectionSize int
+	// MaxRecordSize is the maximal size of a record, including its section definitions. The section definitions
+	// after the limit are kept, so that the end of the record is found.
+	MaxRecordSize int
+	// Truncate cuts oversized parts and notes them in Record.Truncations. Otherwise a record with an oversized
+	// part is rejected with a *LimitError.
+	Truncate bool
+}
+
+// LimitError rejects a record which exceeds the Limits of the reader.
+type LimitError struct {
+	Truncation Truncation
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("The %s exceeds the maximal size of %d bytes in section %s, line %d",
+		e.Truncation.Limit, e.Truncation.Max, e.Truncation.Section, e.Truncation.Line)
+}
+
+// SetLimits bounds the memory used by a single record. JSON records are single lines, a JSON record which
+// exceeds MaxLineSize is always rejected with a *LimitError.
+func (r *RecordReader) SetLimits(limits Limits) {
+	r.buffer.Limits = limits
+}
+
+// readLimitedLine reads a line like bufio.Reader.ReadString('\n'). The content of the line is cut after
+// MaxLineSize bytes, size is the size of the line before and truncated tells whether it was cut.
+func (r *readBuffer) readLimitedLine() (line string, size int, truncated bool, err error) {
+	maxLineSize := r.Limits.MaxLineSize
+	if maxLineSize <= 0 {
+		line, err = r.reader.ReadString('\n')
+		return line, len(line), false, err
+	}
+	var builder strings.Builder
+	for {
+		fragment, err := r.reader.ReadSlice('\n')
+		size += len(fragment)
+		content := fragment
+		if err == nil {
+			content = fragment[:len(fragment)-1]
+		}
+		if len(content) > maxLineSize-builder.Len() {
+			truncated = true
+			content = content[:maxLineSize-builder.Len()]
+		}
+		builder.Write(content)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == nil {
+			builder.WriteRune('\n')
+		}
+		return builder.String(), size, truncated, err
+	}
+}
+
+// keepLine counts a line of a section body into the sizes of the section and the record. A line beyond
+// MaxSectionSize or MaxRecordSize is dropped, the first dropped line of a section is noted as truncation.
+func (r *readBuffer) keepLine(line string) (keep bool) {
+	size := len(line) + 1
+	r.sectionSize += size
+	if r.Limits.MaxSectionSize > 0 && r.sectionSize > r.Limits.MaxSectionSize {
+		if !r.sectionTruncated {
+			r.sectionTruncated = true
+			r.truncate(LimitSection, r.Limits.MaxSectionSize)
+		}
+		return false
+	}
+	r.recordSize += size
+	if r.Limits.MaxRecordSize > 0 && r.recordSize > r.Limits.MaxRecordSize {
+		if !r.recordTruncated {
+			r.recordTruncated = true
+			r.truncate(LimitRecord, r.Limits.MaxRecordSize)
+		}
+		return false
+	}
+	return true
+}
+
+// keepHistory tells whether the raw text of a record is below MaxRecordSize.
+func (r *readBuffer) keepHistory(historyBuffer *strings.Builder) bool {
+	return r.Limits.MaxRecordSize <= 0 || historyBuffer.Len() < r.Limits.MaxRecordSize
+}
+
+func (r *readBuffer) truncate(limit ELimit, max int) {
+	r.truncations = append(r.truncations, &Truncation{
+		Limit:   limit,
+		Section: r.LastSegmentKey.Key(),
+		Line:    r.linePointer + 1,
+		Max:     max,
+	})
+}
+
+// startRecord resets the sizes and truncations of the previous record.
+func (r *readBuffer) startRecord() {
+	r.recordSize = 0
+	r.recordTruncated = false
+	r.truncations = nil
+}
+
+// startSection resets the size of the previous section, the section definition counts into the record.
+func (r *readBuffer) startSection(definition string) {
+	r.sectionSize = 0
+	r.sectionTruncated = false
+	r.recordSize += len(definition) + 1
+}
+
+// applyTruncations rejects the record with a *LimitError or moves the truncations of the section to the record.
+func (r *Record) applyTruncations(reader *readBuffer) (err error) {
+	if len(reader.truncations) == 0 {
+		return nil
+	}
+	if !reader.Limits.Truncate {
+		return &LimitError{Truncation: *reader.truncations[0]}
+	}
+	r.Truncations = append(r.Truncations, reader.truncations...)
+	reader.truncations = nil
+	return nil
+}
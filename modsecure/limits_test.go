@@ -0,0 +1,161 @@
+package modsecure
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_readBuffer_readLimitedLine(t *testing.T) {
+	type args struct {
+		text        string
+		maxLineSize int
+	}
+	tests := []struct {
+		name          string
+		args          args
+		wantLine      string
+		wantSize      int
+		wantTruncated bool
+		wantErr       error
+	}{
+		{
+			name:     "No limit",
+			args:     args{text: "0123456789012345678901234567890123456789\nnext", maxLineSize: 0},
+			wantLine: "0123456789012345678901234567890123456789\n",
+			wantSize: 41,
+		},
+		{
+			name:     "Line within the limit",
+			args:     args{text: "0123456789\nnext", maxLineSize: 10},
+			wantLine: "0123456789\n",
+			wantSize: 11,
+		},
+		{
+			name:          "Line longer than the buffer",
+			args:          args{text: "0123456789012345678901234567890123456789\nnext", maxLineSize: 20},
+			wantLine:      "01234567890123456789\n",
+			wantSize:      41,
+			wantTruncated: true,
+		},
+		{
+			name:          "Last line without line break",
+			args:          args{text: "0123456789012345678901234567890123456789", maxLineSize: 5},
+			wantLine:      "01234",
+			wantSize:      40,
+			wantTruncated: true,
+			wantErr:       io.EOF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := newReadBuffer(strings.NewReader(tt.args.text), "test", false)
+			buffer.reader = bufio.NewReaderSize(strings.NewReader(tt.args.text), 16)
+			buffer.Limits.MaxLineSize = tt.args.maxLineSize
+			gotLine, gotSize, gotTruncated, err := buffer.readLimitedLine()
+			if err != tt.wantErr {
+				t.Errorf("readBuffer.readLimitedLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotLine != tt.wantLine || gotSize != tt.wantSize || gotTruncated != tt.wantTruncated {
+				t.Errorf("readBuffer.readLimitedLine() = %q, %d, %v, want %q, %d, %v", gotLine, gotSize, gotTruncated, tt.wantLine, tt.wantSize, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestRecordReader_SetLimits(t *testing.T) {
+	tests := []struct {
+		name            string
+		limits          Limits
+		wantTruncations []*Truncation
+		wantErr         *LimitError
+	}{
+		{
+			name:   "Within the limits",
+			limits: Limits{MaxLineSize: 200, MaxSectionSize: 200, MaxRecordSize: 1000},
+		},
+		{
+			name:            "Truncated line",
+			limits:          Limits{MaxLineSize: 100, Truncate: true},
+			wantTruncations: []*Truncation{{Limit: LimitLine, Section: "H", Line: 20, Max: 100}},
+		},
+		{
+			name:    "Rejected line",
+			limits:  Limits{MaxLineSize: 100},
+			wantErr: &LimitError{Truncation: Truncation{Limit: LimitLine, Section: "H", Line: 20, Max: 100}},
+		},
+		{
+			name:            "Truncated sections",
+			limits:          Limits{MaxSectionSize: 100, Truncate: true},
+			wantTruncations: []*Truncation{{Limit: LimitSection, Section: "H", Line: 20, Max: 100}},
+		},
+		{
+			name:            "Truncated record",
+			limits:          Limits{MaxRecordSize: 400, Truncate: true},
+			wantTruncations: []*Truncation{{Limit: LimitRecord, Section: "H", Line: 20, Max: 400}},
+		},
+		{
+			name:    "Rejected record",
+			limits:  Limits{MaxRecordSize: 400},
+			wantErr: &LimitError{Truncation: Truncation{Limit: LimitRecord, Section: "H", Line: 20, Max: 400}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := CreateRecordReader("testdata/multiSection/full_record.txt", false)
+			if err != nil {
+				t.Fatalf("CreateRecordReader() error = %v", err)
+			}
+			defer r.Close()
+			r.SetLimits(tt.limits)
+			historyBuffer := &strings.Builder{}
+			record, err := r.Next(historyBuffer)
+			if tt.wantErr != nil {
+				var limitErr *LimitError
+				if !errors.As(err, &limitErr) || !reflect.DeepEqual(limitErr, tt.wantErr) {
+					t.Errorf("RecordReader.Next() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RecordReader.Next() error = %v", err)
+			}
+			if !reflect.DeepEqual(record.Truncations, tt.wantTruncations) {
+				t.Errorf("Record.Truncations = %v, want %v", record.Truncations, tt.wantTruncations)
+			}
+			if record.ResumePosition != (Position{Offset: 650, Line: 28}) {
+				t.Errorf("Record.ResumePosition = %v, want the end of the file", record.ResumePosition)
+			}
+			if len(tt.wantTruncations) > 0 && historyBuffer.Len() >= 650 {
+				t.Errorf("history has %d bytes, want the truncated record", historyBuffer.Len())
+			}
+		})
+	}
+}
+
+func TestRecordReader_IterParallelLimits(t *testing.T) {
+	filename := writeTestLog(t, 20, 0)
+	limits := Limits{MaxLineSize: 100, Truncate: true}
+	sequential, err := CreateRecordReader(filename, false)
+	if err != nil {
+		t.Fatalf("CreateRecordReader() error = %v", err)
+	}
+	sequential.SetLimits(limits)
+	want := readAllLossy(sequential)
+	r, err := CreateRecordReader(filename, false)
+	if err != nil {
+		t.Fatalf("CreateRecordReader() error = %v", err)
+	}
+	r.SetLimits(limits)
+	got := collectRecordAndRaws(r.iterChunks(context.Background(), 2, 1))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RecordReader.iterChunks() =\n is   %v,\n want %v", got, want)
+	}
+	if len(got) != 20 || len(got[19].Record.Truncations) != 1 {
+		t.Errorf("RecordReader.iterChunks() returned %d records, want 20 truncated records", len(got))
+	}
+}
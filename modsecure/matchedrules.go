@@ -0,0 +1,180 @@
+package modsecure
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	phaseNames = map[string]int{
+		"request":  2,
+		"response": 4,
+		"logging":  5,
+	}
+)
+
+// parseMatchedRulesInformation parses Section K. Every rule is written on its own line, rules are separated
+// by empty lines. The lines following a rule without an empty line in between are its chained rules, a
+// chained rule that did not match is prefixed with "#".
+func parseMatchedRulesInformation(body []string) (section *SectionKMatchedRuleInformation, err error) {
+	section = &SectionKMatchedRuleInformation{
+		Rules: make([]*MatchedRule, 0, 1),
+	}
+	var parent *MatchedRule
+	for i, elem := range body {
+		if strings.TrimSpace(elem) == "" {
+			parent = nil
+			continue
+		}
+		rule, err := parseMatchedRule(elem)
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("Invalid rule in line %d of section", i+1))
+		}
+		if parent == nil {
+			parent = rule
+			section.Rules = append(section.Rules, rule)
+		} else {
+			parent.Chain = append(parent.Chain, rule)
+		}
+	}
+	return section, nil
+}
+
+// parseMatchedRule parses a single directive, e.g.
+// `SecRule ARGS "@rx union" "phase:2,id:1000,deny,msg:'SQL injection, union'"`
+func parseMatchedRule(line string) (rule *MatchedRule, err error) {
+	rule = &MatchedRule{
+		Raw:     line,
+		Matched: true,
+	}
+	if strings.HasPrefix(line, "#") {
+		rule.Matched = false
+		line = line[1:]
+	}
+	tokens, err := splitDirective(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("Empty rule")
+	}
+	rule.Directive = tokens[0]
+	var actions string
+	switch rule.Directive {
+	case "SecRule":
+		if len(tokens) < 3 {
+			return nil, errors.New(fmt.Sprintf("Invalid SecRule: \"%s\"", line))
+		}
+		rule.Variables = tokens[1]
+		rule.Operator = tokens[2]
+		if len(tokens) > 3 {
+			actions = tokens[3]
+		}
+	case "SecAction":
+		if len(tokens) > 1 {
+			actions = tokens[1]
+		}
+	default:
+		// Other directives (e.g. SecMarker) are kept with their raw text only.
+		return rule, nil
+	}
+	rule.Actions = splitActions(actions)
+	for _, action := range rule.Actions {
+		switch action.Name {
+		case "id":
+			rule.ID = action.Value
+		case "phase":
+			phase, ok := phaseNames[action.Value]
+			if !ok {
+				phase, err = strconv.Atoi(action.Value)
+				if err != nil {
+					return nil, errors.New(fmt.Sprintf("Invalid phase: %s", action.Value))
+				}
+			}
+			rule.Phase = phase
+		}
+	}
+	return rule, nil
+}
+
+// splitDirective splits a directive into its whitespace separated arguments. Double quoted arguments may
+// contain whitespace and backslash escaped quotes; the quotes are removed.
+func splitDirective(line string) (tokens []string, err error) {
+	tokens = make([]string, 0, 4)
+	var current strings.Builder
+	inToken := false
+	inQuote := false
+	for i := 0; i < len(line); i++ {
+		char := line[i]
+		switch {
+		case inQuote && char == '\\' && i+1 < len(line) && line[i+1] == '"':
+			current.WriteByte('"')
+			i++
+		case char == '"':
+			inQuote = !inQuote
+			inToken = true
+		case !inQuote && (char == ' ' || char == '\t' || char == '\r'):
+			if inToken {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				inToken = false
+			}
+		default:
+			current.WriteByte(char)
+			inToken = true
+		}
+	}
+	if inQuote {
+		return nil, errors.New(fmt.Sprintf("Unterminated quote: %s", line))
+	}
+	if inToken {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
+
+// splitActions splits an action list like "id:1,msg:'a, b',t:none" at the commas outside of single quotes.
+func splitActions(actions string) (list []RuleAction) {
+	list = make([]RuleAction, 0, 4)
+	var current strings.Builder
+	inQuote := false
+	flush := func() {
+		action := strings.TrimSpace(current.String())
+		current.Reset()
+		if action == "" {
+			return
+		}
+		splitterated := strings.SplitN(action, ":", 2)
+		ruleAction := RuleAction{
+			Name: strings.TrimSpace(splitterated[0]),
+		}
+		if len(splitterated) == 2 {
+			value := strings.TrimSpace(splitterated[1])
+			if len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") {
+				value = value[1 : len(value)-1]
+			}
+			ruleAction.Value = value
+		}
+		list = append(list, ruleAction)
+	}
+	for i := 0; i < len(actions); i++ {
+		char := actions[i]
+		switch {
+		case inQuote && char == '\\' && i+1 < len(actions):
+			current.WriteByte(char)
+			current.WriteByte(actions[i+1])
+			i++
+		case char == '\'':
+			inQuote = !inQuote
+			current.WriteByte(char)
+		case !inQuote && char == ',':
+			flush()
+		default:
+			current.WriteByte(char)
+		}
+	}
+	flush()
+	return list
+}
@@ -0,0 +1,154 @@
+package modsecure
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	unknownContentType = "<Unknown Content-Type>"
+)
+
+// parseMultipartFilesInformation parses Section J. ModSecurity writes one line per uploaded file:
+//
+//	1,128,"shell.php","application/x-php"
+//
+// followed by "Total,128". The form field names are not written, see addFieldNames.
+func parseMultipartFilesInformation(body []string) (section *SectionJMultipartFileInformation, err error) {
+	section = &SectionJMultipartFileInformation{
+		Files: make([]*MultipartFile, 0, 1),
+	}
+	for _, elem := range body {
+		columns, err := splitFileInformationColumns(elem)
+		if err != nil {
+			return nil, err
+		}
+		if columns[0] == "Total" {
+			if len(columns) != 2 {
+				return nil, errors.New(fmt.Sprintf("Invalid file information total: \"%s\"", elem))
+			}
+			section.TotalSize, err = strconv.ParseUint(columns[1], 10, 64)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Invalid file information total: \"%s\"", elem))
+			}
+			continue
+		}
+		if len(columns) < 4 {
+			return nil, errors.New(fmt.Sprintf("Invalid file information: \"%s\"", elem))
+		}
+		file := &MultipartFile{
+			FileName:    columns[2],
+			ContentType: columns[3],
+		}
+		file.Index, err = strconv.Atoi(columns[0])
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid file information, index is broken: %s", columns[0]))
+		}
+		file.Size, err = strconv.ParseUint(columns[1], 10, 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid file information, size is broken: %s", columns[1]))
+		}
+		if file.ContentType == unknownContentType {
+			file.ContentType = ""
+		}
+		section.Files = append(section.Files, file)
+	}
+	return section, nil
+}
+
+// splitFileInformationColumns splits a line at the commas outside of double quotes and removes the quotes.
+// Inside a quoted column \" and \\ are unescaped, other escapes like \x22 for non-printable bytes are kept as written.
+func splitFileInformationColumns(line string) (columns []string, err error) {
+	columns = make([]string, 0, 4)
+	var current strings.Builder
+	inQuote := false
+	for i := 0; i < len(line); i++ {
+		char := line[i]
+		switch {
+		case inQuote && char == '\\' && i+1 < len(line) && (line[i+1] == '"' || line[i+1] == '\\'):
+			i++
+			current.WriteByte(line[i])
+		case char == '"':
+			inQuote = !inQuote
+		case !inQuote && char == ',':
+			columns = append(columns, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(char)
+		}
+	}
+	if inQuote {
+		return nil, errors.New(fmt.Sprintf("Unterminated quote: %s", line))
+	}
+	columns = append(columns, current.String())
+	return columns, nil
+}
+
+func parseReducedMultipartRequestBody(body []string) (section *SectionIReducedMultipartRequestBody, err error) {
+	raw := strings.Join(body, "\n")
+	return &SectionIReducedMultipartRequestBody{
+		Raw:    raw,
+		Fields: parseUrlEncodedFields(raw),
+	}, nil
+}
+
+// addFieldNames takes the form field names of the files from the decoded multipart request body of Section C,
+// if it was logged.
+func (s *SectionJMultipartFileInformation) addFieldNames(body *DecodedBody) {
+	if body == nil {
+		return
+	}
+	used := make([]bool, len(body.Multipart))
+	for _, file := range s.Files {
+		for i, part := range body.Multipart {
+			if !used[i] && part.FileName != "" && part.FileName == file.FileName {
+				file.FieldName = part.Name
+				used[i] = true
+				break
+			}
+		}
+	}
+}
+
+// addFilePlaceholders appends a placeholder field for every uploaded file, ModSecurity leaves them out of Section I.
+func (s *SectionIReducedMultipartRequestBody) addFilePlaceholders(files []*MultipartFile) {
+	for _, file := range files {
+		s.Fields = append(s.Fields, &FormField{
+			Name:     file.FieldName,
+			IsFile:   true,
+			FileName: file.FileName,
+			Size:     file.Size,
+		})
+	}
+}
+
+// parseUrlEncodedFields decodes "a=1&b=2" keeping the order and duplicates of the fields.
+// Names or values which are not valid url-encoding are kept as they are.
+func parseUrlEncodedFields(raw string) (fields []*FormField) {
+	fields = make([]*FormField, 0, 1)
+	for _, elem := range strings.Split(raw, "&") {
+		if elem == "" {
+			continue
+		}
+		splitterated := strings.SplitN(elem, "=", 2)
+		field := &FormField{
+			Name: unescapeFormValue(splitterated[0]),
+		}
+		if len(splitterated) == 2 {
+			field.Value = unescapeFormValue(splitterated[1])
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func unescapeFormValue(value string) string {
+	unescaped, err := url.QueryUnescape(value)
+	if err != nil {
+		return value
+	}
+	return unescaped
+}
@@ -0,0 +1,199 @@
+package modsecure
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	offsetIndexVersion = 1
+	// OffsetIndexSuffix is appended to the name of a log file for the name of its sidecar index.
+	OffsetIndexSuffix = ".idx"
+)
+
+// OffsetIndex maps the records of a log file to their positions, so single records can be read without
+// scanning the file from its start.
+type OffsetIndex struct {
+	Version int `json:"version"`
+	// Source is the indexed log file. Size and ModTime tell whether the index is up to date.
+	Source       string              `json:"source"`
+	Size         int64               `json:"size"`
+	ModTime      time.Time           `json:"modTime"`
+	Format       EFormat             `json:"format"`
+	DebugSkipper bool                `json:"debugSkipper"`
+	Entries      []*OffsetIndexEntry `json:"entries"`
+}
+
+// OffsetIndexEntry is a single record of an OffsetIndex.
+type OffsetIndexEntry struct {
+	Id            string    `json:"id"`
+	TransactionID string    `json:"transactionId"`
+	Timestamp     time.Time `json:"timestamp"`
+	Start         Position  `json:"start"`
+	End           Position  `json:"end"`
+}
+
+// BuildOffsetIndex reads the whole log file and indexes its records. Broken records are left out.
+func BuildOffsetIndex(filename string, debugSkipper bool, format EFormat) (index *OffsetIndex, err error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := CreateRecordReader(filename, debugSkipper)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	reader.SetFormat(format)
+	index = &OffsetIndex{
+		Version:      offsetIndexVersion,
+		Source:       filename,
+		Size:         info.Size(),
+		ModTime:      info.ModTime(),
+		Format:       format,
+		DebugSkipper: debugSkipper,
+		Entries:      make([]*OffsetIndexEntry, 0, 64),
+	}
+	historyBuffer := &strings.Builder{}
+	for reader.HasNext() {
+		start := reader.Position()
+		record, err := reader.Next(historyBuffer)
+		historyBuffer.Reset()
+		if err == ErrEndReached {
+			break
+		}
+		if err != nil {
+			reader.PeekToNextValidStart(historyBuffer)
+			historyBuffer.Reset()
+			continue
+		}
+		entry := &OffsetIndexEntry{
+			Id:    record.Id,
+			Start: start,
+			End:   record.ResumePosition,
+		}
+		if record.AuditHeader != nil {
+			entry.TransactionID = record.AuditHeader.TransactionID
+			entry.Timestamp = record.AuditHeader.Timestamp
+		}
+		index.Entries = append(index.Entries, entry)
+	}
+	return index, nil
+}
+
+// LoadOffsetIndex reads an index saved by OffsetIndex.Save.
+func LoadOffsetIndex(indexFilename string) (index *OffsetIndex, err error) {
+	payload, err := os.ReadFile(indexFilename)
+	if err != nil {
+		return nil, err
+	}
+	index = &OffsetIndex{}
+	err = json.Unmarshal(payload, index)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("Invalid index %s", indexFilename))
+	}
+	if index.Version != offsetIndexVersion {
+		return nil, errors.New(fmt.Sprintf("Unsupported version %d of index %s", index.Version, indexFilename))
+	}
+	return index, nil
+}
+
+// LoadOrBuildOffsetIndex loads the sidecar index of filename (filename + OffsetIndexSuffix). An index which
+// is missing or out of date is built and saved as sidecar. The sidecar is only a cache, failing to save it,
+// e.g. in a read-only log directory, is no error.
+func LoadOrBuildOffsetIndex(filename string, debugSkipper bool, format EFormat) (index *OffsetIndex, err error) {
+	indexFilename := filename + OffsetIndexSuffix
+	index, err = LoadOffsetIndex(indexFilename)
+	if err == nil {
+		index.Source = filename
+		upToDate, err := index.IsUpToDate()
+		if err != nil {
+			return nil, err
+		}
+		if upToDate && index.Format == format && index.DebugSkipper == debugSkipper {
+			return index, nil
+		}
+	}
+	index, err = BuildOffsetIndex(filename, debugSkipper, format)
+	if err != nil {
+		return nil, err
+	}
+	index.Save(indexFilename)
+	return index, nil
+}
+
+// IsUpToDate tells whether Source was not changed since the index was built.
+func (i *OffsetIndex) IsUpToDate() (upToDate bool, err error) {
+	info, err := os.Stat(i.Source)
+	if err != nil {
+		return false, err
+	}
+	return info.Size() == i.Size && info.ModTime().Equal(i.ModTime), nil
+}
+
+// Save writes the index to indexFilename. The file is replaced atomically.
+func (i *OffsetIndex) Save(indexFilename string) (err error) {
+	payload, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+	temporary := indexFilename + ".tmp"
+	err = os.WriteFile(temporary, payload, 0644)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(temporary, indexFilename)
+	if err != nil {
+		os.Remove(temporary)
+		return err
+	}
+	return nil
+}
+
+// Lookup returns the entry of the record with the transaction ID of Section A or the record ID of the
+// section boundaries, nil if there is none.
+func (i *OffsetIndex) Lookup(id string) (entry *OffsetIndexEntry) {
+	for _, elem := range i.Entries {
+		if elem.TransactionID == id || elem.Id == id {
+			return elem
+		}
+	}
+	return nil
+}
+
+// Between returns the entries of the records with a timestamp in [from, to).
+func (i *OffsetIndex) Between(from time.Time, to time.Time) (entries []*OffsetIndexEntry) {
+	entries = make([]*OffsetIndexEntry, 0, 8)
+	for _, elem := range i.Entries {
+		if !elem.Timestamp.Before(from) && elem.Timestamp.Before(to) {
+			entries = append(entries, elem)
+		}
+	}
+	return entries
+}
+
+// ReadRecord reads the record of entry from Source, starting at the position of the entry.
+func (i *OffsetIndex) ReadRecord(entry *OffsetIndexEntry) (record *Record, err error) {
+	reader, err := CreateRecordReader(i.Source, i.DebugSkipper)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	reader.SetFormat(i.Format)
+	err = reader.SkipTo(entry.Start)
+	if err != nil {
+		return nil, err
+	}
+	record, err = reader.Next(&strings.Builder{})
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("Failed to read record %s", entry.Id))
+	}
+	if record.Id != entry.Id {
+		return nil, errors.New(fmt.Sprintf("Index of %s is out of date, found record %s instead of %s", i.Source, record.Id, entry.Id))
+	}
+	return record, nil
+}
@@ -0,0 +1,118 @@
+package modsecure
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func copyTestFile(t *testing.T, source string, target string) {
+	payload, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v", err)
+	}
+	if err = os.WriteFile(target, payload, 0644); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+}
+
+func TestLoadOrBuildOffsetIndex(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "modsec_audit.log")
+	copyTestFile(t, "testdata/multiSection/v3_records.txt", filename)
+
+	index, err := LoadOrBuildOffsetIndex(filename, false, FormatSerial)
+	if err != nil {
+		t.Fatalf("LoadOrBuildOffsetIndex() error = %v", err)
+	}
+	if len(index.Entries) != 2 {
+		t.Fatalf("OffsetIndex.Entries = %d, want 2", len(index.Entries))
+	}
+	if _, err = os.Stat(filename + OffsetIndexSuffix); err != nil {
+		t.Errorf("sidecar index was not saved: %v", err)
+	}
+	loaded, err := LoadOrBuildOffsetIndex(filename, false, FormatSerial)
+	if err != nil {
+		t.Fatalf("LoadOrBuildOffsetIndex() of sidecar error = %v", err)
+	}
+	if len(loaded.Entries) != 2 || loaded.Entries[1].Start != index.Entries[1].Start {
+		t.Errorf("loaded OffsetIndex.Entries = %v, want %v", loaded.Entries, index.Entries)
+	}
+
+	for _, id := range []string{"157963413712.118220", "Yb7sd1aD"} {
+		entry := loaded.Lookup(id)
+		if entry == nil {
+			t.Errorf("OffsetIndex.Lookup(%s) = nil", id)
+			continue
+		}
+		record, err := loaded.ReadRecord(entry)
+		if err != nil {
+			t.Errorf("OffsetIndex.ReadRecord() error = %v", err)
+			continue
+		}
+		if record.Id != "Yb7sd1aD" || record.RecordLine != 23 {
+			t.Errorf("OffsetIndex.ReadRecord() = %s line %d, want Yb7sd1aD line 23", record.Id, record.RecordLine)
+		}
+	}
+	if entry := loaded.Lookup("missing"); entry != nil {
+		t.Errorf("OffsetIndex.Lookup(missing) = %v, want nil", entry)
+	}
+
+	// Appending a record makes the sidecar out of date.
+	appendTestFile(t, filename, "\n")
+	later := time.Now().Add(time.Minute)
+	if err = os.Chtimes(filename, later, later); err != nil {
+		t.Fatalf("os.Chtimes() error = %v", err)
+	}
+	upToDate, err := loaded.IsUpToDate()
+	if err != nil || upToDate {
+		t.Errorf("OffsetIndex.IsUpToDate() = %v, %v, want false", upToDate, err)
+	}
+}
+
+func TestOffsetIndex_Between(t *testing.T) {
+	index, err := BuildOffsetIndex("testdata/multiSection/v3_records.txt", false, FormatSerial)
+	if err != nil {
+		t.Fatalf("BuildOffsetIndex() error = %v", err)
+	}
+	tests := []struct {
+		name    string
+		from    time.Time
+		to      time.Time
+		wantIds []string
+	}{
+		{name: "All", from: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), to: time.Date(2020, time.February, 1, 0, 0, 0, 0, time.UTC), wantIds: []string{"Xw5hd7cC", "Yb7sd1aD"}},
+		{name: "Second only", from: time.Date(2020, time.January, 21, 19, 15, 37, 0, time.UTC), to: time.Date(2020, time.January, 21, 19, 15, 38, 0, time.UTC), wantIds: []string{"Yb7sd1aD"}},
+		{name: "None", from: time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC), to: time.Date(2019, time.February, 1, 0, 0, 0, 0, time.UTC), wantIds: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := index.Between(tt.from, tt.to)
+			gotIds := make([]string, 0, len(entries))
+			for _, elem := range entries {
+				gotIds = append(gotIds, elem.Id)
+			}
+			if len(gotIds) != len(tt.wantIds) || (len(gotIds) > 0 && gotIds[0] != tt.wantIds[0]) {
+				t.Errorf("OffsetIndex.Between() = %v, want %v", gotIds, tt.wantIds)
+			}
+		})
+	}
+}
+
+func TestOffsetIndex_ReadRecordJSON(t *testing.T) {
+	index, err := BuildOffsetIndex("testdata/json/v2_records.txt", false, FormatJSON)
+	if err != nil {
+		t.Fatalf("BuildOffsetIndex() error = %v", err)
+	}
+	entry := index.Lookup("W7x4gn8AAQEAAEA1Bv4AAAAB")
+	if entry == nil {
+		t.Fatalf("OffsetIndex.Lookup() = nil")
+	}
+	record, err := index.ReadRecord(entry)
+	if err != nil {
+		t.Fatalf("OffsetIndex.ReadRecord() error = %v", err)
+	}
+	if record.RecordLine != 3 {
+		t.Errorf("OffsetIndex.ReadRecord() line = %d, want 3", record.RecordLine)
+	}
+}
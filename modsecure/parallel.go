@@ -0,0 +1,211 @@
+package modsecure
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"strings"
+)
+
+const (
+	// defaultChunkSize is the minimal size of a chunk. A chunk ends before the first Section A after this size.
+	defaultChunkSize = 1 << 20
+)
+
+// recordChunk is a part of a serial audit log which starts at a Section A, or at the start of the log.
+type recordChunk struct {
+	text  string
+	start Position
+	// cutLines are the original sizes of the lines which were cut by MaxLineSize, by line number.
+	cutLines map[int]int
+	// items receives the parsed records of the chunk, it is buffered, so workers never wait for the output.
+	items chan []*RecordAndRaw
+}
+
+// IterParallel is IterLossy, which parses the records with workers goroutines. The log is split into chunks
+// at the Section A boundaries, the records are returned in the order of the log. workers < 1 uses one worker per CPU.
+// JSON audit logs, concurrent audit logs, followed logs and reassembled logs are read by IterLossy.
+// The reader must not be read before, apart from SkipTo.
+func (r *RecordReader) IterParallel(workers int) <-chan *RecordAndRaw {
+	return r.IterParallelContext(context.Background(), workers)
+}
+
+// IterParallelContext is IterParallel, which additionally stops when ctx is done. Err is set to the error of ctx then.
+func (r *RecordReader) IterParallelContext(ctx context.Context, workers int) <-chan *RecordAndRaw {
+	if r.buffer.Format == FormatJSON || r.storageDir != "" || r.buffer.Follow || r.reassembler != nil {
+		return r.IterLossyContext(ctx)
+	}
+	return r.iterChunks(ctx, workers, defaultChunkSize)
+}
+
+func (r *RecordReader) iterChunks(ctx context.Context, workers int, chunkSize int) <-chan *RecordAndRaw {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	ch := make(chan *RecordAndRaw)
+	r.closeOnDone(ctx)
+	jobs := make(chan *recordChunk)
+	// pending holds the chunks in the order of the log, it limits the chunks in work.
+	pending := make(chan *recordChunk, 2*workers)
+	var splitErr error
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		splitErr = r.splitChunks(jobs, pending, chunkSize)
+	}()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for chunk := range jobs {
+				chunk.items <- parseChunk(chunk, r.buffer)
+			}
+		}()
+	}
+	go func() {
+		defer close(ch)
+		defer r.Close()
+		for chunk := range pending {
+			var items []*RecordAndRaw
+			select {
+			case items = <-chunk.items:
+			case <-ctx.Done():
+				r.setIterError(ctx, nil)
+				return
+			case <-r.done:
+				r.setIterError(ctx, nil)
+				return
+			}
+			for _, item := range items {
+				select {
+				case ch <- item:
+				case <-ctx.Done():
+					r.setIterError(ctx, nil)
+					return
+				case <-r.done:
+					r.setIterError(ctx, nil)
+					return
+				}
+			}
+		}
+		r.setIterError(ctx, splitErr)
+	}()
+	return ch
+}
+
+// splitChunks reads the log line by line and cuts it into chunks of at least chunkSize bytes. Every chunk is
+// sent to pending first, so the output keeps the order of the log, and to jobs afterwards.
+func (r *RecordReader) splitChunks(jobs chan<- *recordChunk, pending chan<- *recordChunk, chunkSize int) (err error) {
+	buffer := r.buffer
+	start := buffer.position()
+	text := &strings.Builder{}
+	text.Grow(chunkSize)
+	lines := 0
+	size := int64(0)
+	cutLines := make(map[int]int)
+	if buffer.hasLastReadLine {
+		text.WriteString(buffer.lastReadLine)
+		text.WriteString(buffer.lastReadLineEnding)
+		buffer.hasLastReadLine = false
+		size = int64(buffer.lastReadLineLength)
+		lines = 1
+	}
+	emit := func() bool {
+		chunk := &recordChunk{
+			text:     text.String(),
+			start:    start,
+			cutLines: cutLines,
+			items:    make(chan []*RecordAndRaw, 1),
+		}
+		start = Position{Offset: start.Offset + size, Line: start.Line + lines}
+		text = &strings.Builder{}
+		text.Grow(chunkSize)
+		lines = 0
+		size = 0
+		cutLines = make(map[int]int)
+		select {
+		case pending <- chunk:
+		case <-r.done:
+			return false
+		}
+		select {
+		case jobs <- chunk:
+		case <-r.done:
+			return false
+		}
+		return true
+	}
+	for {
+		line, lineSize, truncated, err := buffer.readLimitedLine()
+		buffer.readOffset += int64(lineSize)
+		if line != "" && text.Len() >= chunkSize && isChunkStart(line, buffer.Dialect) {
+			if !emit() {
+				return nil
+			}
+		}
+		if line != "" {
+			text.WriteString(line)
+			size += int64(lineSize)
+			lines++
+			if truncated {
+				cutLines[start.Line+lines] = lineSize
+			}
+		}
+		if err != nil {
+			buffer.IsFinished = true
+			if text.Len() > 0 && !emit() {
+				return nil
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// isChunkStart tells whether a line, including its line break, starts a Section A.
+func isChunkStart(line string, dialect EDialect) bool {
+	content, _ := splitLineEnding(line)
+	success, _, sectionType, _ := parseSectionDefinitionDialect(content, dialect)
+	return success && sectionType == AuditHeader
+}
+
+// parseChunk parses all records of a chunk like IterLossy. Positions and line numbers refer to the whole log.
+func parseChunk(chunk *recordChunk, template *readBuffer) (items []*RecordAndRaw) {
+	buffer := newReadBuffer(strings.NewReader(chunk.text), template.Name, template.DebugSkipper)
+	buffer.DecodeResponseBodies = template.DecodeResponseBodies
+	buffer.Dialect = template.Dialect
+	buffer.Limits = template.Limits
+	buffer.RawLineEndings = template.RawLineEndings
+	buffer.Provenance = template.Provenance
+	buffer.cutLines = chunk.cutLines
+	buffer.readOffset = chunk.start.Offset
+	buffer.linePointer = chunk.start.Line
+	items = make([]*RecordAndRaw, 0, 8)
+	historyBuffer := &strings.Builder{}
+	for !buffer.IsFinished {
+		item := &RecordAndRaw{}
+		start := buffer.position()
+		record, err := ReadSingleRecord(buffer, historyBuffer)
+		if err == ErrEndReached && historyBuffer.Len() == 0 {
+			break
+		}
+		if err != nil {
+			JumpToNextValidStart(buffer, historyBuffer)
+			buffer.discardCaptured(buffer.position().Offset)
+			item.Err = err
+		} else {
+			item.Record = record
+			if buffer.Provenance {
+				record.Source = buffer.source(start)
+			}
+		}
+		item.ResumePosition = buffer.position()
+		if item.Record != nil {
+			item.Record.ResumePosition = item.ResumePosition
+		}
+		item.Raw = historyBuffer.String()
+		historyBuffer.Reset()
+		items = append(items, item)
+	}
+	return items
+}
@@ -0,0 +1,236 @@
+package modsecure
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTestLog writes a serial audit log of count copies of full_record.txt. Every brokenEvery-th record has
+// a broken Section B, brokenEvery 0 writes no broken records.
+func writeTestLog(tb testing.TB, count int, brokenEvery int) (filename string) {
+	payload, err := os.ReadFile("testdata/multiSection/full_record.txt")
+	if err != nil {
+		tb.Fatalf("os.ReadFile() error = %v", err)
+	}
+	record := string(payload)
+	log := &strings.Builder{}
+	for i := 0; i < count; i++ {
+		text := strings.ReplaceAll(record, "7a1c2d3e", fmt.Sprintf("%08x", i))
+		if brokenEvery > 0 && i%brokenEvery == brokenEvery-1 {
+			text = strings.Replace(text, "POST /upload HTTP/1.1", "broken request line", 1)
+		}
+		log.WriteString(text)
+	}
+	filename = filepath.Join(tb.TempDir(), "modsec_audit.log")
+	if err = os.WriteFile(filename, []byte(log.String()), 0644); err != nil {
+		tb.Fatalf("os.WriteFile() error = %v", err)
+	}
+	return filename
+}
+
+func collectRecordAndRaws(ch <-chan *RecordAndRaw) (items []*RecordAndRaw) {
+	items = make([]*RecordAndRaw, 0, 64)
+	for item := range ch {
+		items = append(items, item)
+	}
+	return items
+}
+
+// readAllLossy reads the records like IterLossy, without its goroutine.
+func readAllLossy(r *RecordReader) (items []*RecordAndRaw) {
+	defer r.Close()
+	items = make([]*RecordAndRaw, 0, 64)
+	historyBuffer := &strings.Builder{}
+	for r.HasNext() {
+		item := &RecordAndRaw{}
+		record, err := r.Next(historyBuffer)
+		if err != nil {
+			r.PeekToNextValidStart(historyBuffer)
+		} else {
+			item.Record = record
+		}
+		item.ResumePosition = r.Position()
+		item.Raw = historyBuffer.String()
+		historyBuffer.Reset()
+		items = append(items, item)
+	}
+	return items
+}
+
+func TestRecordReader_IterParallel(t *testing.T) {
+	filename := writeTestLog(t, 50, 7)
+	sequential, err := CreateRecordReader(filename, false)
+	if err != nil {
+		t.Fatalf("CreateRecordReader() error = %v", err)
+	}
+	want := readAllLossy(sequential)
+	type args struct {
+		workers   int
+		chunkSize int
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{
+			name: "One record per chunk",
+			args: args{workers: 4, chunkSize: 1},
+		},
+		{
+			name: "Several records per chunk",
+			args: args{workers: 3, chunkSize: 4096},
+		},
+		{
+			name: "Single worker",
+			args: args{workers: 1, chunkSize: 1},
+		},
+		{
+			name: "Single chunk",
+			args: args{workers: 2, chunkSize: defaultChunkSize},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := CreateRecordReader(filename, false)
+			if err != nil {
+				t.Fatalf("CreateRecordReader() error = %v", err)
+			}
+			got := collectRecordAndRaws(r.iterChunks(context.Background(), tt.args.workers, tt.args.chunkSize))
+			if r.Err != nil {
+				t.Fatalf("RecordReader.iterChunks() error = %v", r.Err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("RecordReader.iterChunks() returned %d records, want %d", len(got), len(want))
+			}
+			for i := range got {
+				if (got[i].Err != nil) != (got[i].Record == nil) {
+					t.Errorf("Record %d = %v, error = %v", i, got[i].Record, got[i].Err)
+				}
+				got[i].Err = nil
+				if !reflect.DeepEqual(got[i], want[i]) {
+					t.Errorf("Record %d =\n is   %#v,\n want %#v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRecordReader_IterParallelSkipTo(t *testing.T) {
+	filename := writeTestLog(t, 10, 0)
+	r, err := CreateRecordReader(filename, false)
+	if err != nil {
+		t.Fatalf("CreateRecordReader() error = %v", err)
+	}
+	items := collectRecordAndRaws(r.IterParallel(2))
+	if len(items) != 10 {
+		t.Fatalf("RecordReader.IterParallel() returned %d records, want 10", len(items))
+	}
+	r, err = CreateRecordReader(filename, false)
+	if err != nil {
+		t.Fatalf("CreateRecordReader() error = %v", err)
+	}
+	if err = r.SkipTo(items[3].ResumePosition); err != nil {
+		t.Fatalf("RecordReader.SkipTo() error = %v", err)
+	}
+	resumed := collectRecordAndRaws(r.IterParallel(2))
+	if len(resumed) != 6 || resumed[0].Record == nil || resumed[0].Record.Id != "00000004" {
+		t.Fatalf("RecordReader.IterParallel() after SkipTo = %d records, want 6 starting with 00000004", len(resumed))
+	}
+	if !reflect.DeepEqual(resumed[0], items[4]) {
+		t.Errorf("RecordReader.IterParallel() after SkipTo =\n is   %#v,\n want %#v", resumed[0], items[4])
+	}
+}
+
+func TestRecordReader_IterParallelLineEndings(t *testing.T) {
+	filename := writeTestLog(t, 20, 7)
+	payload, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v", err)
+	}
+	if err = os.WriteFile(filename, []byte(strings.ReplaceAll(string(payload), "\n", "\r\n")), 0644); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+	sequential, err := CreateRecordReader(filename, false)
+	if err != nil {
+		t.Fatalf("CreateRecordReader() error = %v", err)
+	}
+	sequential.SetRawLineEndings(true)
+	want := readAllLossy(sequential)
+	r, err := CreateRecordReader(filename, false)
+	if err != nil {
+		t.Fatalf("CreateRecordReader() error = %v", err)
+	}
+	r.SetRawLineEndings(true)
+	got := collectRecordAndRaws(r.iterChunks(context.Background(), 2, 1))
+	for i := range got {
+		got[i].Err = nil
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RecordReader.iterChunks() =\n is   %v,\n want %v", got, want)
+	}
+	if len(got) != 20 || got[19].Record == nil || got[19].ResumePosition.Offset != int64(len(payload)+strings.Count(string(payload), "\n")) {
+		t.Errorf("RecordReader.iterChunks() returned %d records, want 20 ending at the end of the log", len(got))
+	}
+}
+
+func TestRecordReader_IterParallelClose(t *testing.T) {
+	filename := writeTestLog(t, 200, 0)
+	r, err := CreateRecordReader(filename, false)
+	if err != nil {
+		t.Fatalf("CreateRecordReader() error = %v", err)
+	}
+	ch := r.iterChunks(context.Background(), 2, 1)
+	<-ch
+	r.Close()
+	count := len(collectRecordAndRaws(ch))
+	if count >= 199 {
+		t.Errorf("RecordReader.iterChunks() returned %d records after Close", count)
+	}
+	if r.Err != nil {
+		t.Errorf("RecordReader.Err = %v, want nil", r.Err)
+	}
+}
+
+func BenchmarkRecordReader_IterLossy(b *testing.B) {
+	filename := writeTestLog(b, 20000, 0)
+	info, err := os.Stat(filename)
+	if err != nil {
+		b.Fatalf("os.Stat() error = %v", err)
+	}
+	b.SetBytes(info.Size())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, err := CreateRecordReader(filename, false)
+		if err != nil {
+			b.Fatalf("CreateRecordReader() error = %v", err)
+		}
+		for range r.IterLossy() {
+		}
+	}
+}
+
+func BenchmarkRecordReader_IterParallel(b *testing.B) {
+	filename := writeTestLog(b, 20000, 0)
+	info, err := os.Stat(filename)
+	if err != nil {
+		b.Fatalf("os.Stat() error = %v", err)
+	}
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(info.Size())
+			for i := 0; i < b.N; i++ {
+				r, err := CreateRecordReader(filename, false)
+				if err != nil {
+					b.Fatalf("CreateRecordReader() error = %v", err)
+				}
+				for range r.IterParallel(workers) {
+				}
+			}
+		})
+	}
+}
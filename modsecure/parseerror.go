@@ -0,0 +1,178 @@
+package modsecure
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// EErrorCategory tells why a record could not be parsed. A category is an error itself, so
+// errors.Is(err, CategoryIPAddress) tells whether err is a ParseError of this category.
+type EErrorCategory int
+
+const (
+	CategoryUnknown EErrorCategory = iota
+	// CategoryIncompleteRecord is a record which ends before Section Z.
+	CategoryIncompleteRecord
+	// CategorySectionStart is a line which is no section definition, or no Section A at the start of a record.
+	CategorySectionStart
+	CategoryDuplicateSection
+	CategoryAuditHeader
+	CategoryTimestamp
+	CategoryIPAddress
+	CategoryPort
+	CategoryRequestLine
+	CategoryStatusLine
+	CategoryHeader
+	CategoryBody
+	CategoryAuditLogTrailer
+	CategoryRuleMatch
+	CategoryMultipart
+	CategoryMatchedRules
+	// CategoryLimit is a record which exceeds the Limits of the reader, the cause is a *LimitError.
+	CategoryLimit
+	CategoryJSON
+	CategoryIndex
+)
+
+var (
+	errorCategoryNames = map[EErrorCategory]string{
+		CategoryUnknown:          "unknown",
+		CategoryIncompleteRecord: "incompleteRecord",
+		CategorySectionStart:     "sectionStart",
+		CategoryDuplicateSection: "duplicateSection",
+		CategoryAuditHeader:      "auditHeader",
+		CategoryTimestamp:        "timestamp",
+		CategoryIPAddress:        "ipAddress",
+		CategoryPort:             "port",
+		CategoryRequestLine:      "requestLine",
+		CategoryStatusLine:       "statusLine",
+		CategoryHeader:           "header",
+		CategoryBody:             "body",
+		CategoryAuditLogTrailer:  "auditLogTrailer",
+		CategoryRuleMatch:        "ruleMatch",
+		CategoryMultipart:        "multipart",
+		CategoryMatchedRules:     "matchedRules",
+		CategoryLimit:            "limit",
+		CategoryJSON:             "json",
+		CategoryIndex:            "index",
+	}
+	// sectionErrorCategories are the categories of errors without a more specific category.
+	sectionErrorCategories = map[EStructure]EErrorCategory{
+		AuditHeader:                 CategoryAuditHeader,
+		RequestHeader:               CategoryHeader,
+		RequestBody:                 CategoryBody,
+		IntendedResponseHeader:      CategoryHeader,
+		IntendedResponseBody:        CategoryBody,
+		ResponseHeader:              CategoryHeader,
+		ResponseBody:                CategoryBody,
+		AuditLogTrailer:             CategoryAuditLogTrailer,
+		ReducedMultipartRequestBody: CategoryMultipart,
+		MultipartFilesInformation:   CategoryMultipart,
+		MatchedRulesInformation:     CategoryMatchedRules,
+	}
+)
+
+func (c EErrorCategory) String() string {
+	return errorCategoryNames[c]
+}
+
+func (c EErrorCategory) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c EErrorCategory) Error() string {
+	return c.String()
+}
+
+// ParseError is returned for a record which could not be parsed. The error message is the one of the cause.
+type ParseError struct {
+	// File is the name of the reader, see RecordReader.Name.
+	File string
+	// Line is the number of the first line of the broken part, Offset its position in bytes like Position.
+	Line   int
+	Offset int64
+	// Section is the key of the broken section, e.g. "A", empty if the section is unknown.
+	Section  string
+	Category EErrorCategory
+	// Raw is the text of the broken part, e.g. the section or the JSON line.
+	Raw string
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the category of the error.
+func (e *ParseError) Is(target error) bool {
+	category, ok := target.(EErrorCategory)
+	return ok && category == e.Category
+}
+
+// categoryError gives an error of a parse function a more specific category than the one of its section.
+type categoryError struct {
+	category EErrorCategory
+	err      error
+}
+
+func (e *categoryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *categoryError) Unwrap() error {
+	return e.err
+}
+
+// Is matches the category of the error, like ParseError.Is.
+func (e *categoryError) Is(target error) bool {
+	category, ok := target.(EErrorCategory)
+	return ok && category == e.category
+}
+
+func newCategoryError(category EErrorCategory, message string) error {
+	return withCategory(category, errors.New(message))
+}
+
+func withCategory(category EErrorCategory, err error) error {
+	return &categoryError{category: category, err: err}
+}
+
+// newParseError wraps err with its location. The category is taken from err, else it is fallback, else the
+// category of section.
+func (r *readBuffer) newParseError(err error, section EStructure, fallback EErrorCategory, start Position, raw string) *ParseError {
+	parseErr := &ParseError{
+		File:     r.Name,
+		Line:     start.Line + 1,
+		Offset:   start.Offset,
+		Section:  section.Key(),
+		Category: fallback,
+		Raw:      raw,
+		Err:      err,
+	}
+	var categoryErr *categoryError
+	var limitErr *LimitError
+	switch {
+	case errors.As(err, &limitErr):
+		parseErr.Category = CategoryLimit
+	case errors.As(err, &categoryErr):
+		parseErr.Category = categoryErr.category
+	case fallback == CategoryUnknown:
+		parseErr.Category = sectionErrorCategories[section]
+	}
+	return parseErr
+}
+
+// rawSince returns the history written since start, or the peeked line if nothing was written.
+func (r *readBuffer) rawSince(historyBuffer *strings.Builder, start int) string {
+	if historyBuffer.Len() > start {
+		return historyBuffer.String()[start:]
+	}
+	if r.hasLastReadLine {
+		return r.lastReadLine
+	}
+	return ""
+}
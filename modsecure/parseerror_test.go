@@ -0,0 +1,94 @@
+package modsecure
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	validA := "--00000001-A--\n[09/Oct/2018:11:45:38 +0200] W7x4gn8AAQEAAEA1Bv4AAAAA 192.168.1.10 50314 192.168.1.1 80\n\n"
+	validB := "--00000001-B--\nGET / HTTP/1.1\nHost: example.com\n\n"
+	validZ := "--00000001-Z--\n\n"
+	type args struct {
+		text   string
+		format EFormat
+		limits Limits
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    ParseError
+		wantRaw string
+	}{
+		{
+			name:    "Broken IP address",
+			args:    args{text: "--00000001-A--\n[09/Oct/2018:11:45:38 +0200] W7x4gn8AAQEAAEA1Bv4AAAAA 192.168.1.999 50314 192.168.1.1 80\n\n" + validZ},
+			want:    ParseError{File: "test", Line: 1, Offset: 0, Section: "A", Category: CategoryIPAddress},
+			wantRaw: "--00000001-A--\n[09/Oct/2018:11:45:38 +0200] W7x4gn8AAQEAAEA1Bv4AAAAA 192.168.1.999 50314 192.168.1.1 80\n\n",
+		},
+		{
+			name:    "Broken status line",
+			args:    args{text: validA + validB + "--00000001-F--\nHTTP/1.1 OK\n\n" + validZ},
+			want:    ParseError{File: "test", Line: 8, Offset: 153, Section: "F", Category: CategoryStatusLine},
+			wantRaw: "--00000001-F--\nHTTP/1.1 OK\n\n",
+		},
+		{
+			name:    "Broken request header",
+			args:    args{text: validA + "--00000001-B--\nGET / HTTP/1.1\nHost\n\n" + validZ},
+			want:    ParseError{File: "test", Line: 4, Offset: 104, Section: "B", Category: CategoryHeader},
+			wantRaw: "--00000001-B--\nGET / HTTP/1.1\nHost\n\n",
+		},
+		{
+			name:    "Record without Section A",
+			args:    args{text: validB + validZ},
+			want:    ParseError{File: "test", Line: 1, Offset: 0, Section: "B", Category: CategorySectionStart},
+			wantRaw: "--00000001-B--",
+		},
+		{
+			name:    "Incomplete record",
+			args:    args{text: validA + validB},
+			want:    ParseError{File: "test", Line: 1, Offset: 0, Section: "B", Category: CategoryIncompleteRecord},
+			wantRaw: validA + validB,
+		},
+		{
+			name:    "Record exceeds the limits",
+			args:    args{text: validA + validB + validZ, limits: Limits{MaxSectionSize: 20}},
+			want:    ParseError{File: "test", Line: 1, Offset: 0, Section: "A", Category: CategoryLimit},
+			wantRaw: "--00000001-A--\n\n",
+		},
+		{
+			name:    "Broken JSON record",
+			args:    args{text: "\n{\"transaction\": 1}\n", format: FormatJSON},
+			want:    ParseError{File: "test", Line: 2, Offset: 1, Category: CategoryJSON},
+			wantRaw: "{\"transaction\": 1}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := CreateRecordReaderFromReader(strings.NewReader(tt.args.text), "test", false)
+			if err != nil {
+				t.Fatalf("CreateRecordReaderFromReader() error = %v", err)
+			}
+			r.SetFormat(tt.args.format)
+			r.SetLimits(tt.args.limits)
+			_, err = r.Next(&strings.Builder{})
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("RecordReader.Next() error = %#v, want a *ParseError", err)
+			}
+			if !errors.Is(err, tt.want.Category) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.want.Category)
+			}
+			got := *parseErr
+			got.Raw = ""
+			got.Err = nil
+			if got != tt.want {
+				t.Errorf("ParseError = %+v, want %+v", got, tt.want)
+			}
+			if parseErr.Raw != tt.wantRaw {
+				t.Errorf("ParseError.Raw = %q, want %q", parseErr.Raw, tt.wantRaw)
+			}
+		})
+	}
+}
@@ -2,7 +2,7 @@ package modsecure
 
 import (
 	"bufio"
-	//"compress/gzip"
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
 	"io"
@@ -25,106 +25,413 @@ type readBuffer struct {
 	linePointer     int
 	LastSegmentKey  EStructure
 	DebugSkipper    bool
+	DecodeResponseBodies bool
+	Dialect         EDialect
+	Format          EFormat
+	Name            string
+	Follow          bool
+	// readOffset counts the bytes read from reader, including the peeked line of lastReadLineLength bytes.
+	readOffset         int64
+	lastLineLength     int
+	lastReadLineLength int
+	// lineEnding is the line break of the line last returned by PeekLine or ReadLine, "\n", "\r\n" or "" at the
+	// end of the log. lastLineEnding belongs to the line last read from reader, lastReadLineEnding to the peeked line.
+	lineEnding         string
+	lastLineEnding     string
+	lastReadLineEnding string
+	// rawLine is the original text of the line last returned by PeekLine or ReadLine, including its line break and
+	// the comment lines skipped before it by DebugSkipper. lastRawLine and lastReadRawLine are like the line endings.
+	rawLine         string
+	lastRawLine     string
+	lastReadRawLine string
+	// RawLineEndings writes the original line breaks into the history instead of "\n", see SetRawLineEndings.
+	RawLineEndings bool
+	// Provenance collects the bytes read since capturedOffset in captured, see SetProvenance.
+	Provenance     bool
+	captured       []byte
+	capturedOffset int64
+	// seekable is the source of reader if SkipTo can seek it, e.g. an uncompressed file.
+	seekable        io.ReadSeeker
+	isCompressed    bool
+	// closer is the file opened by createBuffer, sources of the caller are not closed.
+	closer          io.Closer
+	Limits          Limits
+	// sizes and truncations of the current record, see keepLine.
+	recordSize       int
+	sectionSize      int
+	recordTruncated  bool
+	sectionTruncated bool
+	truncations      []*Truncation
+	// cutLines are the original sizes of the lines which were cut by the splitter of IterParallel, by line number.
+	cutLines        map[int]int
 }
 
 type RecordReader struct {
 	buffer *readBuffer
 	Err error
+	// storageDir is only set if buffer reads the index file of a concurrent audit log.
+	storageDir string
+	// reassembler is only set if interleaved records are reassembled, see SetReassembly.
+	reassembler *reassembler
+	// done is closed by Close and stops running iterations.
+	done      chan struct{}
+	closeOnce *sync.Once
 }
 
 type RecordAndRaw struct {
 	Record *Record
 	Raw string
+	// ResumePosition is the position after Raw.
+	ResumePosition Position
+	// Err tells why Record is missing, usually it is a *ParseError.
+	Err error
 }
 
 var (
-	errEndReached = errors.New("End reached")
+	// ErrEndReached is returned by Next after the last record.
+	ErrEndReached = errors.New("End reached")
 	errNotMyRecord = errors.New("Not my Segment")
 	// parses: "--26bc3c6f-A--"
 	sectionStartRegex = regexp.MustCompile(`^--([a-z0-9]{8})-([ABCDEFGHIJKZ])--$`)
 	// parses: "[08/Oct/2018:00:00:01 +0200] W7qB4cCoFIQAAHtbutUAAAFI 92.38.32.36 36354 192.168.20.132 443"
-	logHeaderRegex = regexp.MustCompile(`^\[([0-9]{2}/(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)/[0-9]{4}(?::[0-9]{2}){3}\s\+[0-9]{4})\]\s([a-zA-Z0-9\-@]{24,27})\s([0-9]{1,3}(?:\.[0-9]{1,3}){3})\s([0-9]+)\s([0-9]{1,3}(?:\.[0-9]{1,3}){3})\s([0-9]+)$`)
+	// and IPv6 addresses: "[08/Oct/2018:00:00:01 +0200] W7qB4cCoFIQAAHtbutUAAAFI 2001:db8::1 36354 ::ffff:192.168.20.132 443"
+	// The addresses are validated by net.ParseIP.
+	logHeaderRegex = regexp.MustCompile(`^\[([0-9]{2}/(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)/[0-9]{4}(?::[0-9]{2}){3}\s\+[0-9]{4})\]\s([a-zA-Z0-9\-@]{24,27})\s([0-9a-fA-F:.]+)\s([0-9]+)\s([0-9a-fA-F:.]+)\s([0-9]+)$`)
 	// parses: "POST /callback/auth/context/notify/v1.0 HTTP/2.0"
 	headerReqHeadRegex = regexp.MustCompile(`^([A-Z]+)\s([^\s]+)\s([A-Z]+/[0-9.]+)$`)
 	headerResHeadRegex = regexp.MustCompile(`^([A-Z]+/[0-9.]+)\s([0-9]{3,})\s*[A-Za-z\s]*$`)
 	layoutDate = "02/Jan/2006:15:04:05 -0700"
 )
 
-// TODO: Files need to be closed on panic or on other stuff.
-
+// CreateRecordReader opens filename. The file is closed by Close or at the end of Iter and IterLossy.
 func CreateRecordReader(filename string, debugSkipper bool) (reader *RecordReader, err error) {
 	buffer, err := createBuffer(filename, debugSkipper)
 	if err != nil {
 		return nil, err
 	}
+	return createRecordReader(buffer), nil
+}
+
+// CreateRecordReaderFromReader reads the audit log from source, e.g. os.Stdin or a network stream.
+// name is only used to describe the source, e.g. in the output of the parse command.
+// Close does not close source, it belongs to the caller.
+func CreateRecordReaderFromReader(source io.Reader, name string, debugSkipper bool) (reader *RecordReader, err error) {
+	if source == nil {
+		return nil, errors.New("Source must not be nil")
+	}
+	buffer, err := createBufferFromReader(source, name, debugSkipper)
+	if err != nil {
+		return nil, err
+	}
+	return createRecordReader(buffer), nil
+}
+
+func createRecordReader(buffer *readBuffer) (reader *RecordReader) {
 	return &RecordReader{
-		buffer:buffer,
-	}, nil
+		buffer:    buffer,
+		done:      make(chan struct{}),
+		closeOnce: &sync.Once{},
+	}
+}
+
+// Close stops running iterations and closes the file of the reader. It is safe to call Close more than once.
+func (r *RecordReader) Close() (err error) {
+	r.closeOnce.Do(func() {
+		close(r.done)
+		if r.buffer.closer != nil {
+			err = r.buffer.closer.Close()
+		}
+	})
+	return err
+}
+
+func (r *RecordReader) isClosed() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// CreateJSONRecordReader reads an audit log written with "SecAuditLogFormat JSON".
+func CreateJSONRecordReader(filename string, debugSkipper bool) (reader *RecordReader, err error) {
+	reader, err = CreateRecordReader(filename, debugSkipper)
+	if err != nil {
+		return nil, err
+	}
+	reader.SetFormat(FormatJSON)
+	return reader, nil
+}
+
+// SetDecodeResponseBodies turns on removing the transfer and content encoding of Section E and G.
+// The decoded bodies are stored next to the raw bodies.
+func (r *RecordReader) SetDecodeResponseBodies(decode bool) {
+	r.buffer.DecodeResponseBodies = decode
+}
+
+// SetRawLineEndings keeps the original line breaks in the raw text of the records, which is the exact text of the
+// log then, and in the lines of Section C, E, G and K. By default every line of the raw text ends with "\n" and
+// the lines of the sections have no line breaks, whichever line breaks the log uses.
+func (r *RecordReader) SetRawLineEndings(raw bool) {
+	r.buffer.RawLineEndings = raw
+}
+
+// SetDialect selects the dialect of the audit log. The default DialectAutodetect takes the dialect of the first record.
+func (r *RecordReader) SetDialect(dialect EDialect) {
+	r.buffer.Dialect = dialect
+}
+
+// SetFormat selects between the serial audit log and the JSON audit log.
+func (r *RecordReader) SetFormat(format EFormat) {
+	r.buffer.Format = format
+}
+
+// Name returns the name of the source, the filename for readers created with CreateRecordReader.
+func (r *RecordReader) Name() string {
+	return r.buffer.Name
+}
+
+// Dialect returns the selected or detected dialect.
+func (r *RecordReader) Dialect() EDialect {
+	return r.buffer.Dialect
 }
 
 func (r *RecordReader) Next(historyBuffer *strings.Builder) (record *Record, err error) {
-	return ReadSingleRecord(r.buffer, historyBuffer)
+	start := r.buffer.position()
+	if r.storageDir != "" {
+		record, err = r.readIndexedRecord(historyBuffer)
+	} else if r.reassembler != nil && r.buffer.Format == FormatSerial {
+		record, err = r.readReassembledRecord(historyBuffer)
+	} else {
+		record, err = readRecordOfFormat(r.buffer, historyBuffer)
+	}
+	if err != nil {
+		r.buffer.discardCaptured(r.buffer.position().Offset)
+		return nil, err
+	}
+	if r.buffer.Provenance && r.storageDir != "" {
+		// The source of the record is its transaction file, the captured index lines are not needed.
+		r.buffer.discardCaptured(r.buffer.position().Offset)
+	} else if r.buffer.Provenance {
+		if record.Source == nil {
+			record.Source = r.buffer.source(start)
+		}
+		sourceStart := r.filePosition(Position{Offset: record.Source.StartOffset, Line: record.Source.StartLine - 1})
+		sourceEnd := r.filePosition(Position{Offset: record.Source.EndOffset, Line: record.Source.EndLine})
+		record.Source.StartOffset, record.Source.StartLine = sourceStart.Offset, sourceStart.Line+1
+		record.Source.EndOffset, record.Source.EndLine = sourceEnd.Offset, sourceEnd.Line
+	}
+	record.ResumePosition = r.Position()
+	return record, nil
+}
+
+func readRecordOfFormat(reader *readBuffer, historyBuffer *strings.Builder) (record *Record, err error) {
+	if reader.Format == FormatJSON {
+		return ReadSingleJSONRecord(reader, historyBuffer)
+	}
+	return ReadSingleRecord(reader, historyBuffer)
 }
 
 func (r *RecordReader) HasNext() (bool) {
+	if r.reassembler != nil && r.reassembler.hasPending() {
+		return true
+	}
 	return !r.buffer.IsFinished
 }
 
 func (r *RecordReader) PeekToNextValidStart(historyBuffer *strings.Builder) (err error) {
+	if r.buffer.Format == FormatJSON || r.storageDir != "" {
+		// A broken JSON record or index entry never spans more than its own line.
+		skipEmptyLines(r.buffer, historyBuffer)
+		return nil
+	}
+	if r.reassembler != nil {
+		// The sections of a broken record were read already, the following sections may belong to other records.
+		return nil
+	}
 	return JumpToNextValidStart(r.buffer, historyBuffer)
 }
 
+// Iter returns all records of the reader. The iteration stops at the first error, which is stored in Err.
+// The reader is closed when the iteration ends.
 func (r *RecordReader) Iter() <- chan *Record {
+	return r.IterContext(context.Background())
+}
+
+// IterContext is Iter, which additionally stops when ctx is done. Err is set to the error of ctx then.
+// A read blocking in a source of CreateRecordReaderFromReader only stops when the caller closes the source.
+func (r *RecordReader) IterContext(ctx context.Context) <- chan *Record {
 	ch := make(chan *Record)
+	r.closeOnDone(ctx)
 	go func() {
 		defer close(ch)
+		defer r.Close()
 		var historyBuffer *strings.Builder
 		historyBuffer = &strings.Builder{}
 		for r.HasNext() {
 			item, err := r.Next(historyBuffer)
 			historyBuffer.Reset()
+			if err == ErrEndReached {
+				// HasNext can not know whether pending sections of a reassembled record still form a record.
+				r.setIterError(ctx, nil)
+				return
+			}
 			if err != nil {
-				r.Err = err
+				r.setIterError(ctx, err)
+				return
+			}
+			select {
+			case ch <- item:
+			case <-ctx.Done():
+				r.setIterError(ctx, nil)
+				return
+			case <-r.done:
+				r.setIterError(ctx, nil)
 				return
 			}
-			ch <- item
 		}
 	}()
 	return ch
 }
 
+// IterLossy returns all records of the reader and the raw text of every record. Records which could not
+// be parsed have no Record. The reader is closed when the iteration ends.
 func (r *RecordReader) IterLossy() <- chan *RecordAndRaw {
+	return r.IterLossyContext(context.Background())
+}
+
+// IterLossyContext is IterLossy, which additionally stops when ctx is done. Err is set to the error of ctx then.
+func (r *RecordReader) IterLossyContext(ctx context.Context) <- chan *RecordAndRaw {
 	ch := make(chan *RecordAndRaw)
+	r.closeOnDone(ctx)
+	send := func(recAndRaw *RecordAndRaw) bool {
+		select {
+		case ch <- recAndRaw:
+			return true
+		case <-ctx.Done():
+			r.setIterError(ctx, nil)
+			return false
+		case <-r.done:
+			r.setIterError(ctx, nil)
+			return false
+		}
+	}
 	go func() {
 		defer close(ch)
+		defer r.Close()
 		var historyBuffer *strings.Builder
 		historyBuffer = &strings.Builder{}
 		for r.HasNext() {
 			recAndRaw := &RecordAndRaw{}
 			item, err := r.Next(historyBuffer)
+			if err == ErrEndReached {
+				// HasNext can not know whether pending sections of a reassembled record still form a record.
+				r.setIterError(ctx, nil)
+				return
+			}
+			if err != nil && r.isClosed() {
+				// The record was cut off by Close, it is not broken.
+				r.setIterError(ctx, err)
+				return
+			}
 			if err != nil {
 				r.PeekToNextValidStart(historyBuffer)
+				recAndRaw.Err = err
+				recAndRaw.ResumePosition = r.Position()
 				recAndRaw.Raw = historyBuffer.String()
 				historyBuffer.Reset()
-				ch <- recAndRaw
+				if !send(recAndRaw) {
+					return
+				}
+				continue
 			}
 			recAndRaw.Record = item
+			recAndRaw.ResumePosition = r.Position()
 			recAndRaw.Raw = historyBuffer.String()
 			historyBuffer.Reset()
-			ch <- recAndRaw
+			if !send(recAndRaw) {
+				return
+			}
 		}
 	}()
 	return ch
 }
 
+// closeOnDone closes the reader when ctx is done. This also stops a read which waits for more data in follow mode.
+func (r *RecordReader) closeOnDone(ctx context.Context) {
+	if ctx.Done() == nil {
+		return
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.Close()
+		case <-r.done:
+		}
+	}()
+}
+
+// setIterError stores the error which ended an iteration. Errors caused by Close are dropped, unless ctx is done.
+func (r *RecordReader) setIterError(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		r.Err = ctx.Err()
+	} else if !r.isClosed() {
+		r.Err = err
+	}
+}
+
+// skipEmptyLines consumes empty lines, so that the reader knows it is finished after the last record.
+func skipEmptyLines(reader *readBuffer, historyBuffer *strings.Builder) {
+	if reader.Follow {
+		// The next line may not be written yet, waiting for it would hold back the record.
+		return
+	}
+	for {
+		line, err := reader.PeekLine()
+		if err == io.EOF && line == "" {
+			reader.IsFinished = true
+			return
+		}
+		if err != nil || strings.TrimSpace(line) != "" {
+			return
+		}
+		reader.writeLine(historyBuffer, line)
+		reader.AcceptPeekedLine()
+	}
+}
+
 func createBuffer(filename string, debugSkipper bool) (buffer *readBuffer, err error) {
 	file, err := os.OpenFile(filename, os.O_RDONLY, os.ModePerm)
 	if err != nil {
 		//fmt.Println("ERROR: Could not open file")
 		return nil, err
 	}
-	//test, _ := gzip.NewReader(file)
-	reader := bufio.NewReader(file)
+	buffer, err = createBufferFromReader(file, filename, debugSkipper)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	buffer.closer = file
+	if !buffer.isCompressed {
+		buffer.seekable = file
+	}
+	return buffer, nil
+}
+
+// createBufferFromReader reads source, gzip and bzip2 compressed sources are decompressed.
+func createBufferFromReader(source io.Reader, name string, debugSkipper bool) (buffer *readBuffer, err error) {
+	decompressed, compressed, err := decompress(source)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("Failed to read %s", name))
+	}
+	buffer = newReadBuffer(decompressed, name, debugSkipper)
+	buffer.isCompressed = compressed
+	return buffer, nil
+}
+
+func newReadBuffer(source io.Reader, name string, debugSkipper bool) (buffer *readBuffer) {
+	reader := bufio.NewReader(source)
 	buffer = &readBuffer{
 		lastReadLine:    "",
 		hasLastReadLine: false,
@@ -134,8 +441,9 @@ func createBuffer(filename string, debugSkipper bool) (buffer *readBuffer, err e
 		readSectionMutex: &sync.Mutex{},
 		LastSegmentKey: NIL,
 		DebugSkipper: debugSkipper,
+		Name: name,
 	}
-	return buffer, nil
+	return buffer
 }
 
 func (r *readBuffer) ReadLine() (line string, err error) {
@@ -145,23 +453,42 @@ func (r *readBuffer) ReadLine() (line string, err error) {
 	if r.hasLastReadLine {
 		r.hasLastReadLine = false
 		r.linePointer = r.linePointer + 1
+		r.lineEnding = r.lastReadLineEnding
+		r.rawLine = r.lastReadRawLine
 		return r.lastReadLine, nil
 	} else {
 		line, err = r.getLineOrLast()
 		r.linePointer = r.linePointer + 1
+		r.lineEnding = r.lastLineEnding
+		r.rawLine = r.lastRawLine
 		return line, err
 	}
 }
 
 func (r *readBuffer) getLineOrLast() (line string, err error) {
+	skipped := ""
 	for {
-		readString, err := r.reader.ReadString('\n')
+		readString, size, truncated, err := r.readLimitedLine()
+		if original, ok := r.cutLines[r.linePointer+1]; ok {
+			size = original
+			truncated = true
+		}
+		if r.Provenance {
+			r.capture(readString, size, truncated)
+		}
+		r.readOffset += int64(size)
+		r.lastLineLength = size
+		if truncated {
+			r.truncate(LimitLine, r.Limits.MaxLineSize)
+		}
+		r.lastRawLine = skipped + readString
+		readString, r.lastLineEnding = splitLineEnding(readString)
 		if err != nil {
 			return readString, err
 		} else {
-			readString = strings.Trim(readString, "\n")
 			if r.DebugSkipper && strings.HasPrefix(readString, "#") {
 				r.linePointer = r.linePointer + 1
+				skipped = r.lastRawLine
 				continue
 			}
 			return readString, nil
@@ -173,11 +500,38 @@ func (r *readBuffer) PeekLine() (line string, err error) {
 	if !r.hasLastReadLine {
 		line, err = r.getLineOrLast()
 		r.lastReadLine = line
+		r.lastReadLineLength = r.lastLineLength
+		r.lastReadLineEnding = r.lastLineEnding
+		r.lastReadRawLine = r.lastRawLine
 		r.hasLastReadLine = true
 	}
+	r.lineEnding = r.lastReadLineEnding
+	r.rawLine = r.lastReadRawLine
 	return r.lastReadLine, err
 }
 
+// splitLineEnding splits the line break off a line. Lines may end with "\n" or "\r\n", also mixed in one log.
+// The last line of a log may end with a bare "\r".
+func splitLineEnding(line string) (content string, ending string) {
+	switch {
+	case strings.HasSuffix(line, "\r\n"):
+		return line[:len(line)-2], "\r\n"
+	case strings.HasSuffix(line, "\n"), strings.HasSuffix(line, "\r"):
+		return line[:len(line)-1], line[len(line)-1:]
+	}
+	return line, ""
+}
+
+// writeLine writes the line last returned by PeekLine or ReadLine into the history, see RawLineEndings.
+func (r *readBuffer) writeLine(historyBuffer *strings.Builder, line string) {
+	historyBuffer.WriteString(line)
+	if r.RawLineEndings {
+		historyBuffer.WriteString(r.lineEnding)
+	} else {
+		historyBuffer.WriteRune('\n')
+	}
+}
+
 func (r *readBuffer) AcceptPeekedLine() {
 	if r.hasLastReadLine {
 		r.linePointer = r.linePointer + 1
@@ -201,25 +555,19 @@ func JumpToNextValidStart(reader *readBuffer, historyBuffer *strings.Builder) (e
 			if err == io.EOF {
 				//fmt.Println("Finished")
 				reader.IsFinished = true
-				return errEndReached
+				return ErrEndReached
 			}
 			//fmt.Println("ERROR: unexpected behaviour while reading file line by line")
 			return err
 		}
-		success, _, sectionType := parseSectionDefinition(firstLine)
-		if !success {
-			historyBuffer.WriteString(firstLine)
-			historyBuffer.WriteRune('\n')
-			reader.AcceptPeekedLine()
-			continue
+		success, _, sectionType := reader.parseSectionDefinition(firstLine)
+		if success && sectionType == AuditHeader {
+			return nil
 		}
-		if sectionType != AuditHeader {
-			historyBuffer.WriteString(firstLine)
-			historyBuffer.WriteRune('\n')
-			reader.AcceptPeekedLine()
-			continue
+		if reader.keepHistory(historyBuffer) {
+			reader.writeLine(historyBuffer, firstLine)
 		}
-		return nil
+		reader.AcceptPeekedLine()
 	}
 }
 
@@ -227,18 +575,32 @@ func ReadSingleRecord(reader *readBuffer, historyBuffer *strings.Builder) (recor
 	record = &Record{}
 	reader.readRecordMutex.Lock()
 	defer reader.readRecordMutex.Unlock()
+	reader.startRecord()
+	start := reader.position()
+	rawStart := historyBuffer.Len()
 	for {
 		err = record.ReadSection(reader, historyBuffer)
+		if err == nil && reader.Follow && reader.LastSegmentKey == AuditLogFooter {
+			// The record is complete with its footer, the next record may not be written yet.
+			if reader.DecodeResponseBodies {
+				record.decodeResponseBodies()
+			}
+			return record, nil
+		}
 		if err != nil {
 			if record.Id == "" {
 				if reader.IsFinished {
-					return nil, errEndReached
+					return nil, ErrEndReached
 				}
 				return nil, errors.WithMessage(err, "Failed to create new record")
 			}
-			if err == errEndReached || err == errNotMyRecord {
+			if err == ErrEndReached || err == errNotMyRecord {
 				if reader.LastSegmentKey != AuditLogFooter {
-					return nil, errors.New(fmt.Sprintf("Record is not complete. Stopped parsing at line %d", reader.linePointer))
+					return nil, reader.newParseError(errors.New(fmt.Sprintf("Record is not complete. Stopped parsing at line %d", reader.linePointer)),
+						reader.LastSegmentKey, CategoryIncompleteRecord, start, reader.rawSince(historyBuffer, rawStart))
+				}
+				if reader.DecodeResponseBodies {
+					record.decodeResponseBodies()
 				}
 				return record, nil
 			}
@@ -256,162 +618,191 @@ func (r *Record) ReadSection(reader *readBuffer, historyBuffer *strings.Builder)
 		if err == io.EOF {
 			//fmt.Println("Finished")
 			reader.IsFinished = true
-			return errEndReached
+			return ErrEndReached
 		}
 		//fmt.Println("ERROR: unexpected behaviour while reading file line by line")
 		return err
 	}
-	success, sectionName, sectionType := parseSectionDefinition(firstLine)
+	success, sectionName, sectionType := reader.parseSectionDefinition(firstLine)
 	//success, _, _ := parseSectionDefinition(firstLine)
+	start := reader.position()
+	rawStart := historyBuffer.Len()
+	fail := func(err error) error {
+		return reader.newParseError(err, sectionType, CategoryUnknown, start, reader.rawSince(historyBuffer, rawStart))
+	}
 	if !success {
-		return errors.New("Invalid section start")
+		return fail(newCategoryError(CategorySectionStart, "Invalid section start"))
 	}
 	if r.Id == "" {
 		if sectionType != AuditHeader {
-			return errors.New("Invalid section start")
+			return fail(newCategoryError(CategorySectionStart, "Invalid section start"))
 		}
 		r.Id = sectionName
 	} else if r.Id != sectionName {
 		return errNotMyRecord
-	} else if sectionType <= reader.LastSegmentKey {
+	} else if sectionType == reader.LastSegmentKey || sectionOrder(sectionType, reader.Dialect) < sectionOrder(reader.LastSegmentKey, reader.Dialect) {
 		return errNotMyRecord
 	}
-	historyBuffer.WriteString(firstLine)
-	historyBuffer.WriteRune('\n')
+	reader.writeLine(historyBuffer, firstLine)
 	reader.AcceptPeekedLine()
 	reader.LastSegmentKey = sectionType
-	body, err := readSectionBody(reader, historyBuffer)
+	reader.startSection(firstLine)
+	var body []string
+	var rawBody string
+	switch sectionType {
+	case MatchedRulesInformation, RequestBody, IntendedResponseBody, ResponseBody:
+		// Section K separates its rules with empty lines, bodies may contain empty lines.
+		body, rawBody, err = readBlockSectionBody(reader, historyBuffer)
+	default:
+		body, err = readSectionBody(reader, historyBuffer)
+	}
+	if truncationErr := r.applyTruncations(reader); truncationErr != nil {
+		return fail(truncationErr)
+	}
 	switch sectionType {
 	case AuditHeader:
 		{
 			if r.AuditHeader != nil {
-				return errors.New("AuditHeader already set.")
+				return fail(newCategoryError(CategoryDuplicateSection, "AuditHeader already set."))
 			}
-			val, err := parseAuditHeader(body)
+			val, err := parseAuditHeader(body, reader.Dialect)
 			if err != nil {
-				return errors.WithMessage(err, "Failed to parse AuditHeader")
+				return fail(errors.WithMessage(err, "Failed to parse AuditHeader"))
 			}
 			r.AuditHeader = val
 			r.RecordLine = firstLineInt
+			r.Dialect = reader.Dialect
 		}
 	case RequestHeader:
 		{
 			if r.RequestHeader != nil {
-				return errors.New("RequestHeader already set.")
+				return fail(newCategoryError(CategoryDuplicateSection, "RequestHeader already set."))
 			}
 			val, err := parseRequestHeader(body)
 			if err != nil {
-				return errors.WithMessage(err, "Failed to parse RequestHeader")
+				return fail(errors.WithMessage(err, "Failed to parse RequestHeader"))
 			}
 			r.RequestHeader = val
 		}
 	case RequestBody:
 		{
 			if r.RequestBody != nil {
-				return errors.New("RequestBody already set.")
+				return fail(newCategoryError(CategoryDuplicateSection, "RequestBody already set."))
 			}
 			val, err := parseRequestBody(body)
 			if err != nil {
-				return errors.WithMessage(err, "Failed to parse RequestBody")
+				return fail(errors.WithMessage(err, "Failed to parse RequestBody"))
 			}
 			r.RequestBody = val
+			r.decodeRequestBody(rawBody)
 		}
 	case IntendedResponseHeader:
 		{
 			if r.IntendedResponseHeader != nil {
-				return errors.New("IntendedResponseHeader already set.")
+				return fail(newCategoryError(CategoryDuplicateSection, "IntendedResponseHeader already set."))
 			}
 			val, err := parseIntendedResponseHeader(body)
 			if err != nil {
-				return errors.WithMessage(err, "Failed to parse IntendedResponseHeader")
+				return fail(errors.WithMessage(err, "Failed to parse IntendedResponseHeader"))
 			}
 			r.IntendedResponseHeader = val
 		}
 	case IntendedResponseBody:
 		{
 			if r.IntendedResponseBody != nil {
-				return errors.New("IntendedResponseBody already set.")
+				return fail(newCategoryError(CategoryDuplicateSection, "IntendedResponseBody already set."))
 			}
 			val, err := parseIntendedResponseBody(body)
 			if err != nil {
-				return errors.WithMessage(err, "Failed to parse IntendedResponseBody")
+				return fail(errors.WithMessage(err, "Failed to parse IntendedResponseBody"))
 			}
 			r.IntendedResponseBody = val
+			if reader.DecodeResponseBodies {
+				r.rawIntendedResponseBody = rawBody
+			}
 		}
 	case ResponseHeader:
 		{
 			if r.ResponseHeader != nil {
-				return errors.New("ResponseHeader already set.")
+				return fail(newCategoryError(CategoryDuplicateSection, "ResponseHeader already set."))
 			}
 			val, err := parseResponseHeader(body)
 			if err != nil {
-				return errors.WithMessage(err, "Failed to parse ResponseHeader")
+				return fail(errors.WithMessage(err, "Failed to parse ResponseHeader"))
 			}
 			r.ResponseHeader = val
 		}
 	case ResponseBody:
 		{
 			if r.ResponseBody != nil {
-				return errors.New("ResponseBody already set.")
+				return fail(newCategoryError(CategoryDuplicateSection, "ResponseBody already set."))
 			}
 			val, err := parseResponseBody(body)
 			if err != nil {
-				return errors.WithMessage(err, "Failed to parse ResponseBody")
+				return fail(errors.WithMessage(err, "Failed to parse ResponseBody"))
 			}
 			r.ResponseBody = val
+			if reader.DecodeResponseBodies {
+				r.rawResponseBody = rawBody
+			}
 		}
 	case AuditLogTrailer:
 		{
 			if r.AuditLogTrailer != nil {
-				return errors.New("AuditLogTrailer already set.")
+				return fail(newCategoryError(CategoryDuplicateSection, "AuditLogTrailer already set."))
 			}
 			val, err := parseAuditLogTrailer(body)
 			if err != nil {
-				return errors.WithMessage(err, "Failed to parse AuditLogTrailer")
+				return fail(errors.WithMessage(err, "Failed to parse AuditLogTrailer"))
 			}
 			r.AuditLogTrailer = val
+			r.RuleMatches = parseRuleMatches(val.Messages)
 		}
 	case ReducedMultipartRequestBody:
 		{
 			if r.ReducedMultipartRequestBody != nil {
-				return errors.New("ReducedMultipartRequestBody already set.")
+				return fail(newCategoryError(CategoryDuplicateSection, "ReducedMultipartRequestBody already set."))
 			}
 			val, err := parseReducedMultipartRequestBody(body)
 			if err != nil {
-				return errors.WithMessage(err, "Failed to parse ReducedMultipartRequestBody")
+				return fail(errors.WithMessage(err, "Failed to parse ReducedMultipartRequestBody"))
 			}
 			r.ReducedMultipartRequestBody = val
 		}
 	case MultipartFilesInformation:
 		{
 			if r.MultipartFilesInformation != nil {
-				return errors.New("MultipartFilesInformation already set.")
+				return fail(newCategoryError(CategoryDuplicateSection, "MultipartFilesInformation already set."))
 			}
 			val, err := parseMultipartFilesInformation(body)
 			if err != nil {
-				return errors.WithMessage(err, "Failed to parse MultipartFilesInformation")
+				return fail(errors.WithMessage(err, "Failed to parse MultipartFilesInformation"))
 			}
 			r.MultipartFilesInformation = val
+			val.addFieldNames(r.DecodedRequestBody)
+			if r.ReducedMultipartRequestBody != nil {
+				r.ReducedMultipartRequestBody.addFilePlaceholders(val.Files)
+			}
 		}
 	case MatchedRulesInformation:
 		{
 			if r.MatchedRulesInformation != nil {
-				return errors.New("MatchedRulesInformation already set.")
+				return fail(newCategoryError(CategoryDuplicateSection, "MatchedRulesInformation already set."))
 			}
 			val, err := parseMatchedRulesInformation(body)
 			if err != nil {
-				return errors.WithMessage(err, "Failed to parse MatchedRulesInformation")
+				return fail(errors.WithMessage(err, "Failed to parse MatchedRulesInformation"))
 			}
 			r.MatchedRulesInformation = val
 		}
 	case AuditLogFooter:
 		{
 			if r.AuditLogFooter != nil {
-				return errors.New("AuditLogFooter already set.")
+				return fail(newCategoryError(CategoryDuplicateSection, "AuditLogFooter already set."))
 			}
 			val, err := parseAuditLogFooter(body)
 			if err != nil {
-				return errors.WithMessage(err, "Failed to parse AuditLogFooter")
+				return fail(errors.WithMessage(err, "Failed to parse AuditLogFooter"))
 			}
 			r.AuditLogFooter = val
 		}
@@ -426,58 +817,45 @@ func parseAuditLogFooter(body []string) (section *SectionZAuditLogFooter, err er
 	// TODO: implement this section
 	return nil, nil
 }
-func parseMatchedRulesInformation(body []string) (section *SectionKMatchedRuleInformation, err error) {
-	// TODO: implement this section
-	return nil, nil
-}
-func parseMultipartFilesInformation(body []string) (section *SectionJMultipartFileInformation, err error) {
-	// TODO: implement this section
-	return nil, nil
-}
-func parseReducedMultipartRequestBody(body []string) (section *SectionIReducedMultipartRequestBody, err error) {
-	// TODO: implement this section
-	return nil, nil
-}
-func parseAuditLogTrailer(body []string) (section *SectionHAuditLogTrailer, err error) {
-	// TODO: implement this section
-	return nil, nil
-}
 func parseResponseBody(body []string) (section []string, err error) {
 	return body, nil
 }
 func parseResponseHeader(body []string) (section *SectionFResponseHeaders, err error) {
 	if len(body) < 1 {
-		return nil, errors.New("Body is empty")
+		return nil, newCategoryError(CategoryStatusLine, "Body is empty")
 	}
-	header := make(map[string]string)
+	header := make(Header, 0, len(body)-1)
 	// First line: POST /callback/auth/context/pageview/v1.0 HTTP/1.1
 	// All following lines are one line headers.
 	parsedLine := headerResHeadRegex.FindStringSubmatch(body[0])
 	if parsedLine == nil {
-		return nil, errors.New(fmt.Sprintf("Invalid Response Header: \"%s\"", body[0]))
+		return nil, newCategoryError(CategoryStatusLine, fmt.Sprintf("Invalid Response Header: \"%s\"", body[0]))
 	}
 	subbody := body[1:]
 	for _, elem := range subbody {
 		splitterated := strings.SplitN(elem, ": ", 2)
 		if len(splitterated) < 2 {
-			return nil, errors.New("Invalid Header")
+			return nil, newCategoryError(CategoryHeader, fmt.Sprintf("Invalid Header: \"%s\"", elem))
 		}
-		header[splitterated[0]] = splitterated[1]
+		header.Add(splitterated[0], splitterated[1])
 	}
 	statusCode, err := strconv.Atoi(parsedLine[2])
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("Invalid Header, StatusCode is broken: %s", parsedLine[2]))
+		return nil, newCategoryError(CategoryStatusLine, fmt.Sprintf("Invalid Header, StatusCode is broken: %s", parsedLine[2]))
 	}
 	section = &SectionFResponseHeaders{
 		Protocol: parsedLine[1],
 		Status:   uint16(statusCode),
-		Header:   &header,
+		Header:   header,
 	}
 	return section, nil
 }
 func parseIntendedResponseBody(body []string) (section *SectionEIntendedResponseBody, err error) {
-	// Not implemented in https://github.com/SpiderLabs/ModSecurity/wiki/ModSecurity-2-Data-Formats
-	return nil, nil
+	// Not described in https://github.com/SpiderLabs/ModSecurity/wiki/ModSecurity-2-Data-Formats,
+	// ModSecurity 2.x writes the raw intended response body.
+	return &SectionEIntendedResponseBody{
+		Body: body,
+	}, nil
 }
 
 func parseIntendedResponseHeader(body []string) (section *SectionDIntendedResponseHeader, err error) {
@@ -491,60 +869,67 @@ func parseRequestBody(body []string) (requestBody []string, err error) {
 
 func parseRequestHeader(body []string) (section *SectionBRequestHeader, err error) {
 	if len(body) < 1 {
-		return nil, errors.New("Body is empty")
+		return nil, newCategoryError(CategoryRequestLine, "Body is empty")
 	}
-	header := make(map[string]string)
+	header := make(Header, 0, len(body)-1)
 	// First line: POST /callback/auth/context/pageview/v1.0 HTTP/1.1
 	// All following lines are one line headers.
 	parsedLine := headerReqHeadRegex.FindStringSubmatch(body[0])
 	if parsedLine == nil {
-		return nil, errors.New("Invalid Body")
+		return nil, newCategoryError(CategoryRequestLine, "Invalid Body")
 	}
 	subbody := body[1:]
 	for _, elem := range subbody {
 		splitterated := strings.SplitN(elem, ": ", 2)
 		if len(splitterated) < 2 {
-			return nil, errors.New("Invalid Header")
+			return nil, newCategoryError(CategoryHeader, fmt.Sprintf("Invalid Header: \"%s\"", elem))
 		}
-		header[splitterated[0]] = splitterated[1]
+		header.Add(splitterated[0], splitterated[1])
 	}
 	section = &SectionBRequestHeader{
 		Protocol: parsedLine[3],
 		Method:   parsedLine[1],
 		Path:     parsedLine[2],
-		Header:   &header,
+		Header:   header,
 	}
 	return section, nil
 }
 
-func parseAuditHeader(body []string) (section *SectionAAuditHeader, err error) {
+// parseAuditHeader parses Section A in the given dialect. With DialectAutodetect both dialects are accepted.
+func parseAuditHeader(body []string, dialect EDialect) (section *SectionAAuditHeader, err error) {
 	if len(body) != 1 {
 		return nil, errors.New("Header is longer than 1")
 	}
-	parsedHeader := logHeaderRegex.FindStringSubmatch(body[0])
+	var parsedHeader []string
+	if dialect != DialectV3 {
+		parsedHeader = logHeaderRegex.FindStringSubmatch(body[0])
+	}
+	if parsedHeader == nil && dialect != DialectV2 {
+		parsedHeader = logHeaderV3Regex.FindStringSubmatch(body[0])
+	}
 	if parsedHeader == nil {
 		return nil, errors.New(fmt.Sprintf("Invalid Header, Header string: \"%s\"", body[0]))
 	}
 	date, err := time.Parse(layoutDate, parsedHeader[1])
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("Invalid Header, Date is broken: %s", parsedHeader[1]))
+		return nil, newCategoryError(CategoryTimestamp, fmt.Sprintf("Invalid Header, Date is broken: %s", parsedHeader[1]))
 	}
 	id := parsedHeader[2]
 	sourceIp := net.ParseIP(parsedHeader[3])
 	if sourceIp == nil {
-		return nil, errors.New(fmt.Sprintf("Invalid Header, SourceIp is broken: %s", parsedHeader[3]))
+		return nil, newCategoryError(CategoryIPAddress, fmt.Sprintf("Invalid Header, SourceIp is broken: %s", parsedHeader[3]))
 	}
 	sourcePort, err := strconv.Atoi(parsedHeader[4])
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("Invalid Header, SourcePort is broken: %s", parsedHeader[4]))
+		return nil, newCategoryError(CategoryPort, fmt.Sprintf("Invalid Header, SourcePort is broken: %s", parsedHeader[4]))
 	}
 	destIp := net.ParseIP(parsedHeader[5])
 	if destIp == nil {
-		return nil, errors.New(fmt.Sprintf("Invalid Header, DestIp is broken: %s", parsedHeader[5]))
+		return nil, newCategoryError(CategoryIPAddress, fmt.Sprintf("Invalid Header, DestIp is broken: %s", parsedHeader[5]))
 	}
 	destPort, err := strconv.Atoi(parsedHeader[6])
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("Invalid Header, DestPort is broken: %s", parsedHeader[6]))
+		return nil, newCategoryError(CategoryPort, fmt.Sprintf("Invalid Header, DestPort is broken: %s", parsedHeader[6]))
 	}
 	return &SectionAAuditHeader{
 		Timestamp:       date,
@@ -568,33 +953,104 @@ func readSectionBody(reader *readBuffer, historyBuffer *strings.Builder) (body [
 		}
 		if strings.TrimSpace(line) == "" {
 			// End of section. Removing empty line from read buffer.
-			historyBuffer.WriteRune('\n')
+			reader.writeLine(historyBuffer, line)
 			reader.AcceptPeekedLine()
 			break
 		}
-		if isSectionDefinition(line) {
+		if reader.isSectionDefinition(line) {
 			// End of section. A new section begins. Leaving the head in the buffer for further parsing.
 			break
 		}
-		historyBuffer.WriteString(line)
-		historyBuffer.WriteRune('\n')
+		keep := reader.keepLine(line)
 		reader.AcceptPeekedLine()
+		if !keep {
+			continue
+		}
+		reader.writeLine(historyBuffer, line)
 		lines = append(lines, line)
 	}
 	return lines, err
 }
 
+// readBlockSectionBody reads until the next section definition. Empty lines are kept in the body,
+// trailing empty lines are dropped. With RawLineEndings the lines keep their "\r", joined with "\n" they have the
+// line breaks of the log then. raw is the exact text of the body as written into the log, including
+// comment lines skipped by DebugSkipper, for decoding the body.
+func readBlockSectionBody(reader *readBuffer, historyBuffer *strings.Builder) (body []string, raw string, err error) {
+	lines := make([]string, 0, 1)
+	var rawBody strings.Builder
+	for {
+		line, err := reader.PeekLine()
+		if err != nil && err != io.EOF {
+			return nil, "", err
+		}
+		if err == io.EOF && line == "" {
+			reader.AcceptPeekedLine()
+			break
+		}
+		if reader.isSectionDefinition(line) {
+			break
+		}
+		keep := reader.keepLine(line)
+		reader.AcceptPeekedLine()
+		if !keep {
+			continue
+		}
+		reader.writeLine(historyBuffer, line)
+		rawBody.WriteString(reader.rawLine)
+		if reader.RawLineEndings {
+			line += strings.TrimSuffix(reader.lineEnding, "\n")
+		}
+		lines = append(lines, line)
+	}
+	// The line break in front of the next section definition is not part of the body.
+	raw, _ = splitLineEnding(rawBody.String())
+	return trimTrailingEmptyLines(lines), raw, nil
+}
+
+func trimTrailingEmptyLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// sectionOrders are the positions of the sections in a record of ModSecurity 2. It writes Section I and J right
+// after C and Section F before E, so the response sections D to G share a position and may come in any order.
+var sectionOrders = map[EStructure]int{
+	NIL:                         -1,
+	AuditHeader:                 0,
+	RequestHeader:               1,
+	RequestBody:                 2,
+	ReducedMultipartRequestBody: 3,
+	MultipartFilesInformation:   4,
+	IntendedResponseHeader:      5,
+	IntendedResponseBody:        5,
+	ResponseHeader:              5,
+	ResponseBody:                5,
+	AuditLogTrailer:             6,
+	MatchedRulesInformation:     7,
+	AuditLogFooter:              8,
+}
+
+// sectionOrder is the position of a section in a record, see sectionOrders. libmodsecurity 3 writes the sections
+// in the order of their letters, only the response sections D to G may come in any order as well.
+func sectionOrder(section EStructure, dialect EDialect) int {
+	if dialect != DialectV3 {
+		return sectionOrders[section]
+	}
+	if section > IntendedResponseHeader && section <= ResponseBody {
+		return int(IntendedResponseHeader)
+	}
+	return int(section)
+}
+
 func isSectionDefinition(line string) (success bool) {
 	success, _, _ = parseSectionDefinition(line)
 	return success
 }
 
 func parseSectionDefinition(line string) (success bool, sectionName string, sectionType EStructure) {
-	match := sectionStartRegex.FindStringSubmatch(line)
-	if match == nil {
-		return false, "", NIL
-	} else {
-		sectionType := keyToEStructure[[]rune(match[2])[0]]
-		return true, match[1], sectionType // match 0 is the full match
-	}
+	success, sectionName, sectionType, _ = parseSectionDefinitionDialect(line, DialectAutodetect)
+	return success, sectionName, sectionType
 }
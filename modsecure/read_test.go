@@ -2,9 +2,13 @@ package modsecure
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"net"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 	"github.com/google/go-cmp/cmp"
@@ -213,7 +217,7 @@ func Test_readSectionBody(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			readBuffer := tt.args.reader.create()
 			readBuffer.ReadLine() // Schmeiße die Headerzeile weg
-			gotBody, err := readSectionBody(readBuffer)
+			gotBody, err := readSectionBody(readBuffer, &strings.Builder{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("readSectionBody() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -225,6 +229,91 @@ func Test_readSectionBody(t *testing.T) {
 	}
 }
 
+func Test_readBlockSectionBody(t *testing.T) {
+	testdir := "testdata/single_section/"
+
+	tests := []struct {
+		name           string
+		reader         futureBuffer
+		rawLineEndings bool
+		wantBody       []string
+		wantRaw        string
+		wantErr        bool
+	}{
+		{
+			name: "section block body",
+			reader: futureBuffer{
+				filename: testdir + "section_block_body.txt",
+			},
+			wantBody: []string{
+				`SecAction "id:1"`,
+				"",
+				`SecRule ARGS "@rx a" "id:2,chain"`,
+				`SecRule ARGS "@rx b"`,
+			},
+			wantRaw: "SecAction \"id:1\"\n\nSecRule ARGS \"@rx a\" \"id:2,chain\"\nSecRule ARGS \"@rx b\"\n",
+			wantErr: false,
+		},
+		{
+			name: "section block body CRLF",
+			reader: futureBuffer{
+				filename: testdir + "section_block_body_crlf.txt",
+			},
+			wantBody: []string{"5", "hello", "0"},
+			wantRaw:  "5\r\nhello\r\n0\r\n\r\n",
+			wantErr:  false,
+		},
+		{
+			name: "section block body CRLF with raw line endings",
+			reader: futureBuffer{
+				filename: testdir + "section_block_body_crlf.txt",
+			},
+			rawLineEndings: true,
+			wantBody:       []string{"5\r", "hello\r", "0\r"},
+			wantRaw:        "5\r\nhello\r\n0\r\n\r\n",
+			wantErr:        false,
+		},
+		{
+			name: "section block body EOF",
+			reader: futureBuffer{
+				filename: testdir + "section_block_body_EOF.txt",
+			},
+			wantBody: []string{
+				`SecAction "id:1"`,
+				"",
+				`SecAction "id:2"`,
+			},
+			wantErr: false,
+		},
+		{
+			name: "section empty body",
+			reader: futureBuffer{
+				filename: testdir + "section_empty_body.txt",
+			},
+			wantBody: make([]string, 0, 0),
+			wantErr:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readBuffer := tt.reader.create()
+			readBuffer.RawLineEndings = tt.rawLineEndings
+			readBuffer.ReadLine()
+			gotBody, gotRaw, err := readBlockSectionBody(readBuffer, &strings.Builder{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("readBlockSectionBody() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !cmp.Equal(gotBody, tt.wantBody) {
+				t.Errorf("readBlockSectionBody() = %#v, want %#v", gotBody, tt.wantBody)
+			}
+			if tt.wantRaw != "" && gotRaw != tt.wantRaw {
+				t.Errorf("readBlockSectionBody() raw = %q, want %q", gotRaw, tt.wantRaw)
+			}
+		})
+	}
+}
+
 //var _ = Describe("ModsecParser", func() {
 //	var (
 //		test bool
@@ -288,7 +377,28 @@ func Test_readBuffer_ReadLine(t *testing.T) {
 		wantLine string
 		wantErr  bool
 	}{
-		// TODO: Add test cases.
+		{
+			name:     "Line ending with LF",
+			fields:   fields{reader: bufio.NewReader(strings.NewReader("--7a1c2d3e-A--\nnext"))},
+			wantLine: "--7a1c2d3e-A--",
+		},
+		{
+			name:     "Line ending with CRLF",
+			fields:   fields{reader: bufio.NewReader(strings.NewReader("--7a1c2d3e-A--\r\nnext"))},
+			wantLine: "--7a1c2d3e-A--",
+		},
+		{
+			name:     "Last line without line break",
+			fields:   fields{reader: bufio.NewReader(strings.NewReader("--7a1c2d3e-Z--"))},
+			wantLine: "--7a1c2d3e-Z--",
+			wantErr:  true,
+		},
+		{
+			name:     "Last line ending with CR",
+			fields:   fields{reader: bufio.NewReader(strings.NewReader("--7a1c2d3e-Z--\r"))},
+			wantLine: "--7a1c2d3e-Z--",
+			wantErr:  true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -443,20 +553,20 @@ func TestReadRecord(t *testing.T) {
 				Id:          "26bc3c6f",
 				RequestBody: []string{"Teststring1"},
 				AuditHeader: &SectionAAuditHeader{
-					timestamp:       time.Date(2018, time.October, 8, 0, 0, 1, 0, time.FixedZone("", 0)),
-					transactionID:   "W7qB4cCoFIQAAHtbutUAAAFI",
-					sourceIP:        net.ParseIP("92.38.32.36"),
-					sourcePort:      36354,
-					destinationIP:   net.ParseIP("192.168.20.132"),
-					destinationPort: 443,
+					Timestamp:       time.Date(2018, time.October, 8, 0, 0, 1, 0, time.FixedZone("", 0)),
+					TransactionID:   "W7qB4cCoFIQAAHtbutUAAAFI",
+					SourceIP:        net.ParseIP("92.38.32.36"),
+					SourcePort:      36354,
+					DestinationIP:   net.ParseIP("192.168.20.132"),
+					DestinationPort: 443,
 				},
 				RequestHeader: &SectionBRequestHeader{
 					Protocol: "HTTP/1.1",
 					Method:   "POST",
 					Path:     "/callback/auth/context/pageview/v1.0",
-					Header: &map[string]string{
-						"Accept":       "*/*",
-						"Content-Type": "application/json",
+					Header: Header{
+						{Name: "Accept", Value: "*/*"},
+						{Name: "Content-Type", Value: "application/json"},
 					},
 				},
 			},
@@ -474,20 +584,20 @@ func TestReadRecord(t *testing.T) {
 				Id:          "26bc3c6f",
 				RequestBody: []string{"Teststring1"},
 				AuditHeader: &SectionAAuditHeader{
-					timestamp:       time.Date(2018, time.October, 8, 0, 0, 1, 0, time.FixedZone("", 0)),
-					transactionID:   "W7qB4cCoFIQAAHtbutUAAAFI",
-					sourceIP:        net.ParseIP("92.38.32.36"),
-					sourcePort:      36354,
-					destinationIP:   net.ParseIP("192.168.20.132"),
-					destinationPort: 443,
+					Timestamp:       time.Date(2018, time.October, 8, 0, 0, 1, 0, time.FixedZone("", 0)),
+					TransactionID:   "W7qB4cCoFIQAAHtbutUAAAFI",
+					SourceIP:        net.ParseIP("92.38.32.36"),
+					SourcePort:      36354,
+					DestinationIP:   net.ParseIP("192.168.20.132"),
+					DestinationPort: 443,
 				},
 				RequestHeader: &SectionBRequestHeader{
 					Protocol: "HTTP/1.1",
 					Method:   "POST",
 					Path:     "/callback/auth/context/pageview/v1.0",
-					Header: &map[string]string{
-						"Accept":       "*/*",
-						"Content-Type": "application/json",
+					Header: Header{
+						{Name: "Accept", Value: "*/*"},
+						{Name: "Content-Type", Value: "application/json"},
 					},
 				},
 			},
@@ -505,35 +615,120 @@ func TestReadRecord(t *testing.T) {
 				Id:          "26bc3c6f",
 				RequestBody: []string{"Teststring1"},
 				AuditHeader: &SectionAAuditHeader{
-					timestamp:       time.Date(2018, time.October, 8, 0, 0, 1, 0, time.FixedZone("", 0)),
-					transactionID:   "W7qB4cCoFIQAAHtbutUAAAFI",
-					sourceIP:        net.ParseIP("92.38.32.36"),
-					sourcePort:      36354,
-					destinationIP:   net.ParseIP("192.168.20.132"),
-					destinationPort: 443,
+					Timestamp:       time.Date(2018, time.October, 8, 0, 0, 1, 0, time.FixedZone("", 0)),
+					TransactionID:   "W7qB4cCoFIQAAHtbutUAAAFI",
+					SourceIP:        net.ParseIP("92.38.32.36"),
+					SourcePort:      36354,
+					DestinationIP:   net.ParseIP("192.168.20.132"),
+					DestinationPort: 443,
 				},
 				RequestHeader: &SectionBRequestHeader{
 					Protocol: "HTTP/1.1",
 					Method:   "POST",
 					Path:     "/callback/auth/context/pageview/v1.0",
-					Header: &map[string]string{
-						"Accept":       "*/*",
-						"Content-Type": "application/json",
+					Header: Header{
+						{Name: "Accept", Value: "*/*"},
+						{Name: "Content-Type", Value: "application/json"},
 					},
 				},
 			},
 			wantErr:  false,
 			jumpOver: 1,
 		},
-		// TODO: Add test cases.
+		{
+			name: "Read full record",
+			args: args{
+				&futureBuffer{
+					filename:     "testdata/multiSection/full_record.txt",
+					debugSkipper: false,
+				},
+			},
+			wantRecord: &Record{
+				Id: "7a1c2d3e",
+				AuditHeader: &SectionAAuditHeader{
+					Timestamp:       time.Date(2018, time.October, 8, 0, 0, 1, 0, time.FixedZone("", 2*60*60)),
+					TransactionID:   "W7qB4cCoFIQAAHtbutUAAAFI",
+					SourceIP:        net.ParseIP("92.38.32.36"),
+					SourcePort:      36354,
+					DestinationIP:   net.ParseIP("192.168.20.132"),
+					DestinationPort: 443,
+				},
+				RequestHeader: &SectionBRequestHeader{
+					Protocol: "HTTP/1.1",
+					Method:   "POST",
+					Path:     "/upload",
+					Header: Header{
+						{Name: "Host", Value: "example.org"},
+						{Name: "Content-Type", Value: "multipart/form-data; boundary=xyz"},
+					},
+				},
+				ResponseHeader: &SectionFResponseHeaders{
+					Protocol: "HTTP/1.1",
+					Status:   403,
+					Header: Header{
+						{Name: "Content-Length", Value: "0"},
+					},
+				},
+				AuditLogTrailer: &SectionHAuditLogTrailer{
+					Messages: []string{
+						`Access denied with code 403 (phase 2). Pattern match "\\.php$" at FILES_NAMES. [id "1001"] [severity "CRITICAL"] [tag "upload"]`,
+					},
+					Action:     &TrailerAction{Name: "Intercepted", Phase: 2},
+					EngineMode: "ENABLED",
+				},
+				RuleMatches: []*RuleMatch{
+					{
+						Disposition: "Access denied with code 403 (phase 2)",
+						Description: `Pattern match "\\.php$" at FILES_NAMES.`,
+						ID:          "1001",
+						Severity:    SeverityCritical,
+						Tags:        []string{"upload"},
+					},
+				},
+				ReducedMultipartRequestBody: &SectionIReducedMultipartRequestBody{
+					Raw: "name=Doe",
+					Fields: []*FormField{
+						{Name: "name", Value: "Doe"},
+						{IsFile: true, FileName: "shell.php", Size: 128},
+					},
+				},
+				MultipartFilesInformation: &SectionJMultipartFileInformation{
+					Files: []*MultipartFile{
+						{Index: 1, FileName: "shell.php", Size: 128, ContentType: "application/x-php"},
+					},
+					TotalSize: 128,
+				},
+				MatchedRulesInformation: &SectionKMatchedRuleInformation{
+					Rules: []*MatchedRule{
+						{
+							Raw:       `SecRule FILES_NAMES "@rx \.php$" "phase:2,id:1001,deny"`,
+							Directive: "SecRule",
+							Variables: "FILES_NAMES",
+							Operator:  `@rx \.php$`,
+							Actions: []RuleAction{
+								{Name: "phase", Value: "2"},
+								{Name: "id", Value: "1001"},
+								{Name: "deny"},
+							},
+							ID:      "1001",
+							Phase:   2,
+							Matched: true,
+						},
+					},
+				},
+				RecordLine: 1,
+				Dialect:    DialectV2,
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			reader := tt.args.reader.create()
 			for i := 0; i < tt.jumpOver; i++ {
-				ReadSingleRecord(reader)
+				ReadSingleRecord(reader, &strings.Builder{})
 			}
-			gotRecord, err := ReadSingleRecord(reader)
+			gotRecord, err := ReadSingleRecord(reader, &strings.Builder{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ReadSingleRecord() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -545,7 +740,7 @@ func TestReadRecord(t *testing.T) {
 				}
 				if !reflect.DeepEqual(gotRecord.AuditHeader, tt.wantRecord.AuditHeader) {
 					t.Errorf("ReadSingleRecord.AuditHeader() =\n is   %#v,\n want %#v", gotRecord.AuditHeader, tt.wantRecord.AuditHeader)
-					t.Errorf("%#v", gotRecord.AuditHeader.timestamp.Location())
+					t.Errorf("%#v", gotRecord.AuditHeader.Timestamp.Location())
 				}
 				if !reflect.DeepEqual(gotRecord.RequestHeader, tt.wantRecord.RequestHeader) {
 					t.Errorf("ReadSingleRecord.RequestHeader() =\n is   %#v,\n want %#v", gotRecord.RequestHeader, tt.wantRecord.RequestHeader)
@@ -602,7 +797,7 @@ func TestRecord_ReadSection(t *testing.T) {
 				MatchedRulesInformation:     tt.fields.MatchedRulesInformation,
 				AuditLogFooter:              tt.fields.AuditLogFooter,
 			}
-			if err := r.ReadSection(tt.args.reader); (err != nil) != tt.wantErr {
+			if err := r.ReadSection(tt.args.reader, &strings.Builder{}); (err != nil) != tt.wantErr {
 				t.Errorf("Record.ReadSection() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -645,7 +840,89 @@ func Test_parseMatchedRulesInformation(t *testing.T) {
 		wantSection *SectionKMatchedRuleInformation
 		wantErr     bool
 	}{
-		// TODO: Add test cases.
+		{
+			name: "Single rules and a chain",
+			args: args{
+				body: []string{
+					`SecAction "phase:1,id:900000,nolog,pass,setvar:tx.paranoia_level=1"`,
+					``,
+					`SecRule ARGS "@rx union\s+select" "phase:request,id:1000,deny,msg:'SQL injection, union',chain"`,
+					`SecRule REQUEST_METHOD "@streq POST" "t:none"`,
+					`#SecRule REMOTE_ADDR "!@ipMatch 127.0.0.1"`,
+				},
+			},
+			wantSection: &SectionKMatchedRuleInformation{
+				Rules: []*MatchedRule{
+					{
+						Raw:       `SecAction "phase:1,id:900000,nolog,pass,setvar:tx.paranoia_level=1"`,
+						Directive: "SecAction",
+						Actions: []RuleAction{
+							{Name: "phase", Value: "1"},
+							{Name: "id", Value: "900000"},
+							{Name: "nolog"},
+							{Name: "pass"},
+							{Name: "setvar", Value: "tx.paranoia_level=1"},
+						},
+						ID:      "900000",
+						Phase:   1,
+						Matched: true,
+					},
+					{
+						Raw:       `SecRule ARGS "@rx union\s+select" "phase:request,id:1000,deny,msg:'SQL injection, union',chain"`,
+						Directive: "SecRule",
+						Variables: "ARGS",
+						Operator:  `@rx union\s+select`,
+						Actions: []RuleAction{
+							{Name: "phase", Value: "request"},
+							{Name: "id", Value: "1000"},
+							{Name: "deny"},
+							{Name: "msg", Value: "SQL injection, union"},
+							{Name: "chain"},
+						},
+						ID:      "1000",
+						Phase:   2,
+						Matched: true,
+						Chain: []*MatchedRule{
+							{
+								Raw:       `SecRule REQUEST_METHOD "@streq POST" "t:none"`,
+								Directive: "SecRule",
+								Variables: "REQUEST_METHOD",
+								Operator:  "@streq POST",
+								Actions:   []RuleAction{{Name: "t", Value: "none"}},
+								Matched:   true,
+							},
+							{
+								Raw:       `#SecRule REMOTE_ADDR "!@ipMatch 127.0.0.1"`,
+								Directive: "SecRule",
+								Variables: "REMOTE_ADDR",
+								Operator:  "!@ipMatch 127.0.0.1",
+								Actions:   []RuleAction{},
+								Matched:   false,
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Empty section",
+			args: args{
+				body: []string{},
+			},
+			wantSection: &SectionKMatchedRuleInformation{
+				Rules: []*MatchedRule{},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Unterminated quote",
+			args: args{
+				body: []string{`SecRule ARGS "@rx x`},
+			},
+			wantSection: nil,
+			wantErr:     true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -671,7 +948,49 @@ func Test_parseMultipartFilesInformation(t *testing.T) {
 		wantSection *SectionJMultipartFileInformation
 		wantErr     bool
 	}{
-		// TODO: Add test cases.
+		{
+			name: "Two files",
+			args: args{
+				body: []string{
+					`1,128,"shell.php","application/x-php"`,
+					`2,20,"a \"b\", c\x0a.txt","<Unknown Content-Type>"`,
+					`Total,148`,
+				},
+			},
+			wantSection: &SectionJMultipartFileInformation{
+				Files: []*MultipartFile{
+					{
+						Index:       1,
+						FileName:    "shell.php",
+						Size:        128,
+						ContentType: "application/x-php",
+					},
+					{
+						Index:    2,
+						FileName: `a "b", c\x0a.txt`,
+						Size:     20,
+					},
+				},
+				TotalSize: 148,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Broken size",
+			args: args{
+				body: []string{`1,big,"shell.php","application/x-php"`},
+			},
+			wantSection: nil,
+			wantErr:     true,
+		},
+		{
+			name: "Missing columns",
+			args: args{
+				body: []string{`1,128`},
+			},
+			wantSection: nil,
+			wantErr:     true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -687,6 +1006,27 @@ func Test_parseMultipartFilesInformation(t *testing.T) {
 	}
 }
 
+func TestSectionJMultipartFileInformation_addFieldNames(t *testing.T) {
+	log := "--00000001-A--\n[09/Oct/2018:11:45:38 +0200] W7x4gn8AAQEAAEA1Bv4AAAAA 192.168.1.10 50314 192.168.1.1 80\n" +
+		"--00000001-B--\nPOST /upload HTTP/1.1\nContent-Type: multipart/form-data; boundary=xyz\n\n" +
+		"--00000001-C--\n--xyz\nContent-Disposition: form-data; name=\"note\"\n\nhello\n" +
+		"--xyz\nContent-Disposition: form-data; name=\"upload\"; filename=\"shell.php\"\nContent-Type: application/x-php\n\n<?php ?>\n--xyz--\n" +
+		"--00000001-J--\n1,8,\"shell.php\",\"application/x-php\"\nTotal,8\n\n" +
+		"--00000001-Z--\n\n"
+	r, err := CreateRecordReaderFromReader(strings.NewReader(log), "test", false)
+	if err != nil {
+		t.Fatalf("CreateRecordReaderFromReader() error = %v", err)
+	}
+	record, err := r.Next(&strings.Builder{})
+	if err != nil {
+		t.Fatalf("RecordReader.Next() error = %v", err)
+	}
+	files := record.MultipartFilesInformation.Files
+	if len(files) != 1 || files[0].FieldName != "upload" {
+		t.Errorf("MultipartFile.FieldName = %v, want upload", files)
+	}
+}
+
 func Test_parseReducedMultipartRequestBody(t *testing.T) {
 	type args struct {
 		body []string
@@ -697,7 +1037,33 @@ func Test_parseReducedMultipartRequestBody(t *testing.T) {
 		wantSection *SectionIReducedMultipartRequestBody
 		wantErr     bool
 	}{
-		// TODO: Add test cases.
+		{
+			name: "Form fields",
+			args: args{
+				body: []string{"name=J%C3%B6rg+Doe&tag=a&tag=b&empty&broken=%zz"},
+			},
+			wantSection: &SectionIReducedMultipartRequestBody{
+				Raw: "name=J%C3%B6rg+Doe&tag=a&tag=b&empty&broken=%zz",
+				Fields: []*FormField{
+					{Name: "name", Value: "Jörg Doe"},
+					{Name: "tag", Value: "a"},
+					{Name: "tag", Value: "b"},
+					{Name: "empty"},
+					{Name: "broken", Value: "%zz"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Empty body",
+			args: args{
+				body: []string{},
+			},
+			wantSection: &SectionIReducedMultipartRequestBody{
+				Fields: []*FormField{},
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -723,7 +1089,98 @@ func Test_parseAuditLogTrailer(t *testing.T) {
 		wantSection *SectionHAuditLogTrailer
 		wantErr     bool
 	}{
-		// TODO: Add test cases.
+		{
+			name: "Full trailer",
+			args: args{
+				body: []string{
+					`Message: Warning. Pattern match "(?i)union" at ARGS:q. [id "942100"]`,
+					`Message: Access denied with code 403 (phase 2). [id "949110"]`,
+					`Apache-Error: [file "apache2_util.c"] [line 271] [level 3] ModSecurity: Warning.`,
+					`Apache-Handler: proxy-server`,
+					`Stopwatch: 1538949601019451 23011 (- - -)`,
+					`Stopwatch2: 1538949601019451 23011; combined=1279, p1=502, p2=641, p3=0, p4=0, p5=136, sr=106, sw=0, l=0, gc=0`,
+					`Response-Body-Transformed: Dechunked`,
+					`Producer: ModSecurity for Apache/2.9.2 (http://www.modsecurity.org/); OWASP_CRS/3.0.2.`,
+					`Server: Apache`,
+					`WebApp-Info: "default" "-" ""`,
+					`Engine-Mode: "ENABLED"`,
+					`Action: Intercepted (phase 2)`,
+					`Sanitised-Args: "password", "pin".`,
+					`Sanitised-Request-Headers: "Authorization".`,
+					`X-Custom: foo`,
+				},
+			},
+			wantSection: &SectionHAuditLogTrailer{
+				Messages: []string{
+					`Warning. Pattern match "(?i)union" at ARGS:q. [id "942100"]`,
+					`Access denied with code 403 (phase 2). [id "949110"]`,
+				},
+				ApacheErrors:  []string{`[file "apache2_util.c"] [line 271] [level 3] ModSecurity: Warning.`},
+				ApacheHandler: "proxy-server",
+				Stopwatch: &Stopwatch{
+					Start:       time.Unix(1538949601, 19451000).UTC(),
+					Duration:    23011 * time.Microsecond,
+					Checkpoints: []*time.Duration{nil, nil, nil},
+				},
+				Stopwatch2: &Stopwatch2{
+					Start:       time.Unix(1538949601, 19451000).UTC(),
+					Duration:    23011 * time.Microsecond,
+					Combined:    1279 * time.Microsecond,
+					Phase1:      502 * time.Microsecond,
+					Phase2:      641 * time.Microsecond,
+					Phase5:      136 * time.Microsecond,
+					StorageRead: 106 * time.Microsecond,
+				},
+				ResponseBodyTransformed: "Dechunked",
+				Producer: &Producer{
+					Engine:     "ModSecurity for Apache/2.9.2 (http://www.modsecurity.org/)",
+					Components: []string{"OWASP_CRS/3.0.2"},
+				},
+				Server:                  "Apache",
+				WebAppInfo:              &WebAppInfo{ApplicationID: "default"},
+				EngineMode:              "ENABLED",
+				Action:                  &TrailerAction{Name: "Intercepted", Phase: 2},
+				SanitisedArgs:           []string{"password", "pin"},
+				SanitisedRequestHeaders: []string{"Authorization"},
+				Other:                   map[string][]string{"X-Custom": {"foo"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Empty trailer",
+			args: args{
+				body: []string{},
+			},
+			wantSection: &SectionHAuditLogTrailer{},
+			wantErr:     false,
+		},
+		{
+			name: "Line without name",
+			args: args{
+				body: []string{"Stopwatch", "Server: Apache"},
+			},
+			wantSection: &SectionHAuditLogTrailer{
+				Server: "Apache",
+				Other:  map[string][]string{"": {"Stopwatch"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Broken values",
+			args: args{
+				body: []string{"Stopwatch: now", "Stopwatch2: now", "Action: ", "WebApp-Info: \"default\"", "Engine-Mode: \"ENABLED\""},
+			},
+			wantSection: &SectionHAuditLogTrailer{
+				EngineMode: "ENABLED",
+				Other: map[string][]string{
+					"Stopwatch":   {"now"},
+					"Stopwatch2":  {"now"},
+					"Action":      {""},
+					"WebApp-Info": {"\"default\""},
+				},
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -772,10 +1229,58 @@ func Test_parseResponseHeader(t *testing.T) {
 	tests := []struct {
 		name        string
 		args        args
-		wantSection *SectionFResponseHeaders
-		wantErr     bool
+		wantSection  *SectionFResponseHeaders
+		wantErr      bool
+		wantCategory EErrorCategory
 	}{
-		// TODO: Add test cases.
+		{
+			name: "Repeated headers",
+			args: args{
+				body: []string{
+					"HTTP/1.1 200 OK",
+					"Set-Cookie: a=1",
+					"Content-Length: 10",
+					"Set-Cookie: b=2",
+					"Content-Length: 20",
+				},
+			},
+			wantSection: &SectionFResponseHeaders{
+				Protocol: "HTTP/1.1",
+				Status:   200,
+				Header: Header{
+					{Name: "Set-Cookie", Value: "a=1"},
+					{Name: "Content-Length", Value: "10"},
+					{Name: "Set-Cookie", Value: "b=2"},
+					{Name: "Content-Length", Value: "20"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid status line",
+			args: args{
+				body: []string{"HTTP/1.1 OK"},
+			},
+			wantSection: nil,
+			wantErr:     true,
+		},
+		{
+			name: "Invalid header line",
+			args: args{
+				body: []string{"HTTP/1.1 200 OK", "Content-Length"},
+			},
+			wantSection:  nil,
+			wantErr:      true,
+			wantCategory: CategoryHeader,
+		},
+		{
+			name: "Empty body",
+			args: args{
+				body: []string{},
+			},
+			wantSection: nil,
+			wantErr:     true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -784,6 +1289,9 @@ func Test_parseResponseHeader(t *testing.T) {
 				t.Errorf("parseResponseHeader() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantCategory != CategoryUnknown && !errors.Is(err, tt.wantCategory) {
+				t.Errorf("parseResponseHeader() error = %v, want category %v", err, tt.wantCategory)
+			}
 			if !reflect.DeepEqual(gotSection, tt.wantSection) {
 				t.Errorf("parseResponseHeader() = %v, want %v", gotSection, tt.wantSection)
 			}
@@ -876,10 +1384,32 @@ func Test_parseRequestHeader(t *testing.T) {
 	tests := []struct {
 		name        string
 		args        args
-		wantSection *SectionBRequestHeader
-		wantErr     bool
+		wantSection  *SectionBRequestHeader
+		wantErr      bool
+		wantCategory EErrorCategory
 	}{
-		// TODO: Add test cases.
+		{
+			name: "Headers",
+			args: args{
+				body: []string{"GET / HTTP/1.1", "Host: example.org"},
+			},
+			wantSection: &SectionBRequestHeader{
+				Protocol: "HTTP/1.1",
+				Method:   "GET",
+				Path:     "/",
+				Header:   Header{{Name: "Host", Value: "example.org"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid header line",
+			args: args{
+				body: []string{"GET / HTTP/1.1", "Host"},
+			},
+			wantSection:  nil,
+			wantErr:      true,
+			wantCategory: CategoryHeader,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -888,6 +1418,9 @@ func Test_parseRequestHeader(t *testing.T) {
 				t.Errorf("parseRequestHeader() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantCategory != CategoryUnknown && !errors.Is(err, tt.wantCategory) {
+				t.Errorf("parseRequestHeader() error = %v, want category %v", err, tt.wantCategory)
+			}
 			if !reflect.DeepEqual(gotSection, tt.wantSection) {
 				t.Errorf("parseRequestHeader() = %v, want %v", gotSection, tt.wantSection)
 			}
@@ -897,7 +1430,8 @@ func Test_parseRequestHeader(t *testing.T) {
 
 func Test_parseAuditHeader(t *testing.T) {
 	type args struct {
-		body []string
+		body    []string
+		dialect EDialect
 	}
 	tests := []struct {
 		name        string
@@ -905,11 +1439,105 @@ func Test_parseAuditHeader(t *testing.T) {
 		wantSection *SectionAAuditHeader
 		wantErr     bool
 	}{
-		// TODO: Add test cases.
+		{
+			name: "IPv4",
+			args: args{
+				body: []string{"[08/Oct/2018:00:00:01 +0200] W7qB4cCoFIQAAHtbutUAAAFI 92.38.32.36 36354 192.168.20.132 443"},
+			},
+			wantSection: &SectionAAuditHeader{
+				Timestamp:       time.Date(2018, time.October, 8, 0, 0, 1, 0, time.FixedZone("", 2*60*60)),
+				TransactionID:   "W7qB4cCoFIQAAHtbutUAAAFI",
+				SourceIP:        net.ParseIP("92.38.32.36"),
+				SourcePort:      36354,
+				DestinationIP:   net.ParseIP("192.168.20.132"),
+				DestinationPort: 443,
+			},
+			wantErr: false,
+		},
+		{
+			name: "IPv6",
+			args: args{
+				body: []string{"[08/Oct/2018:00:00:01 +0200] W7qB4cCoFIQAAHtbutUAAAFI 2001:db8::a:1 36354 fe80::1ff:fe23:4567:890a 443"},
+			},
+			wantSection: &SectionAAuditHeader{
+				Timestamp:       time.Date(2018, time.October, 8, 0, 0, 1, 0, time.FixedZone("", 2*60*60)),
+				TransactionID:   "W7qB4cCoFIQAAHtbutUAAAFI",
+				SourceIP:        net.ParseIP("2001:db8::a:1"),
+				SourcePort:      36354,
+				DestinationIP:   net.ParseIP("fe80::1ff:fe23:4567:890a"),
+				DestinationPort: 443,
+			},
+			wantErr: false,
+		},
+		{
+			name: "IPv4-mapped IPv6",
+			args: args{
+				body: []string{"[08/Oct/2018:00:00:01 +0200] W7qB4cCoFIQAAHtbutUAAAFI ::ffff:92.38.32.36 36354 ::1 443"},
+			},
+			wantSection: &SectionAAuditHeader{
+				Timestamp:       time.Date(2018, time.October, 8, 0, 0, 1, 0, time.FixedZone("", 2*60*60)),
+				TransactionID:   "W7qB4cCoFIQAAHtbutUAAAFI",
+				SourceIP:        net.ParseIP("92.38.32.36"),
+				SourcePort:      36354,
+				DestinationIP:   net.ParseIP("::1"),
+				DestinationPort: 443,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid IPv6",
+			args: args{
+				body: []string{"[08/Oct/2018:00:00:01 +0200] W7qB4cCoFIQAAHtbutUAAAFI 2001:db8:::1 36354 ::1 443"},
+			},
+			wantSection: nil,
+			wantErr:     true,
+		},
+		{
+			name: "Invalid IPv4",
+			args: args{
+				body: []string{"[08/Oct/2018:00:00:01 +0200] W7qB4cCoFIQAAHtbutUAAAFI 92.38.32 36354 ::1 443"},
+			},
+			wantSection: nil,
+			wantErr:     true,
+		},
+		{
+			name: "V3 header",
+			args: args{
+				body:    []string{"[21/Jan/2020:19:15:36 -0500] 157963413623.140591 172.17.0.1 36402 172.17.0.2 80"},
+				dialect: DialectV3,
+			},
+			wantSection: &SectionAAuditHeader{
+				Timestamp:       time.Date(2020, time.January, 21, 19, 15, 36, 0, time.FixedZone("", -5*60*60)),
+				TransactionID:   "157963413623.140591",
+				SourceIP:        net.ParseIP("172.17.0.1"),
+				SourcePort:      36402,
+				DestinationIP:   net.ParseIP("172.17.0.2"),
+				DestinationPort: 80,
+			},
+			wantErr: false,
+		},
+		{
+			name: "V3 header with pinned DialectV2",
+			args: args{
+				body:    []string{"[21/Jan/2020:19:15:36 -0500] 157963413623.140591 172.17.0.1 36402 172.17.0.2 80"},
+				dialect: DialectV2,
+			},
+			wantSection: nil,
+			wantErr:     true,
+		},
+		{
+			name: "V2 header with pinned DialectV3",
+			args: args{
+				body:    []string{"[08/Oct/2018:00:00:01 +0200] W7qB4cCoFIQAAHtbutUAAAFI 92.38.32.36 36354 192.168.20.132 443"},
+				dialect: DialectV3,
+			},
+			wantSection: nil,
+			wantErr:     true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotSection, err := parseAuditHeader(tt.args.body)
+			gotSection, err := parseAuditHeader(tt.args.body, tt.args.dialect)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseAuditHeader() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -1031,7 +1659,7 @@ func TestRecordReader_Iter(t *testing.T) {
 				t.Errorf("Error not wanted, got error = %v", err)
 			}
 			for i := 0; i < tt.skipping; i++ {
-				r.Next()
+				r.Next(&strings.Builder{})
 			}
 			i := 0
 			for range r.IterLossy() {
@@ -1045,3 +1673,324 @@ func TestRecordReader_Iter(t *testing.T) {
 		})
 	}
 }
+
+func TestRecordReader_Dialect(t *testing.T) {
+	tests := []struct {
+		name        string
+		filename    string
+		dialect     EDialect
+		wantIds     []string
+		wantDialect EDialect
+		wantErr     bool
+	}{
+		{
+			name:        "Autodetect v3",
+			filename:    "testdata/multiSection/v3_records.txt",
+			dialect:     DialectAutodetect,
+			wantIds:     []string{"Xw5hd7cC", "Yb7sd1aD"},
+			wantDialect: DialectV3,
+			wantErr:     false,
+		},
+		{
+			name:        "Select v3",
+			filename:    "testdata/multiSection/v3_records.txt",
+			dialect:     DialectV3,
+			wantIds:     []string{"Xw5hd7cC", "Yb7sd1aD"},
+			wantDialect: DialectV3,
+			wantErr:     false,
+		},
+		{
+			name:        "Select v2 on a v3 log",
+			filename:    "testdata/multiSection/v3_records.txt",
+			dialect:     DialectV2,
+			wantIds:     []string{},
+			wantDialect: DialectV2,
+			wantErr:     true,
+		},
+		{
+			name:        "Autodetect v2",
+			filename:    "testdata/multiSection/3_records.txt",
+			dialect:     DialectAutodetect,
+			wantIds:     []string{"fghfgjr1", "fghfgjr2", "fghfgjr3"},
+			wantDialect: DialectV2,
+			wantErr:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := CreateRecordReader(tt.filename, false)
+			if err != nil {
+				t.Errorf("CreateRecordReader() error = %v", err)
+				return
+			}
+			r.SetDialect(tt.dialect)
+			gotIds := make([]string, 0, len(tt.wantIds))
+			for record := range r.Iter() {
+				if record.Dialect != tt.wantDialect {
+					t.Errorf("Record.Dialect = %v, want %v", record.Dialect, tt.wantDialect)
+				}
+				gotIds = append(gotIds, record.Id)
+			}
+			if (r.Err != nil) != tt.wantErr {
+				t.Errorf("RecordReader.Iter() error = %v, wantErr %v", r.Err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(gotIds, tt.wantIds) {
+				t.Errorf("RecordReader.Iter() ids = %v, want %v", gotIds, tt.wantIds)
+			}
+			if r.Dialect() != tt.wantDialect {
+				t.Errorf("RecordReader.Dialect() = %v, want %v", r.Dialect(), tt.wantDialect)
+			}
+		})
+	}
+}
+
+func TestRecordReader_V3Record(t *testing.T) {
+	r, err := CreateRecordReader("testdata/multiSection/v3_records.txt", false)
+	if err != nil {
+		t.Errorf("CreateRecordReader() error = %v", err)
+		return
+	}
+	record, err := r.Next(&strings.Builder{})
+	if err != nil {
+		t.Errorf("RecordReader.Next() error = %v", err)
+		return
+	}
+	wantHeader := &SectionAAuditHeader{
+		Timestamp:       time.Date(2020, time.January, 21, 19, 15, 36, 102934000, time.FixedZone("", -5*60*60)),
+		TransactionID:   "157963413623.140591",
+		SourceIP:        net.ParseIP("2001:db8::1"),
+		SourcePort:      36402,
+		DestinationIP:   net.ParseIP("172.17.0.2"),
+		DestinationPort: 80,
+	}
+	if !reflect.DeepEqual(record.AuditHeader, wantHeader) {
+		t.Errorf("Record.AuditHeader =\n is   %#v,\n want %#v", record.AuditHeader, wantHeader)
+	}
+	if record.ResponseHeader == nil || record.ResponseHeader.Status != 403 {
+		t.Errorf("Record.ResponseHeader = %#v, want status 403", record.ResponseHeader)
+	}
+	if len(record.RuleMatches) != 1 {
+		t.Errorf("Record.RuleMatches = %#v, want one match", record.RuleMatches)
+		return
+	}
+	match := record.RuleMatches[0]
+	if match.ID != "941100" || match.Severity != SeverityCritical || match.MatchedVariable != "ARGS:q" || match.Other["unique_id"][0] != "157963413623.140591" {
+		t.Errorf("Record.RuleMatches[0] = %#v", match)
+	}
+}
+
+func TestCreateRecordReaderFromReader(t *testing.T) {
+	content, err := os.ReadFile("testdata/multiSection/v3_records.txt")
+	if err != nil {
+		t.Errorf("os.ReadFile() error = %v", err)
+		return
+	}
+	r, err := CreateRecordReaderFromReader(strings.NewReader(string(content)), "memory", false)
+	if err != nil {
+		t.Errorf("CreateRecordReaderFromReader() error = %v", err)
+		return
+	}
+	if r.Name() != "memory" {
+		t.Errorf("RecordReader.Name() = %v, want memory", r.Name())
+	}
+	gotIds := make([]string, 0, 2)
+	for record := range r.Iter() {
+		gotIds = append(gotIds, record.Id)
+	}
+	if r.Err != nil {
+		t.Errorf("RecordReader.Iter() error = %v", r.Err)
+	}
+	if wantIds := []string{"Xw5hd7cC", "Yb7sd1aD"}; !reflect.DeepEqual(gotIds, wantIds) {
+		t.Errorf("RecordReader.Iter() ids = %v, want %v", gotIds, wantIds)
+	}
+	_, err = CreateRecordReaderFromReader(nil, "nil", false)
+	if err == nil {
+		t.Errorf("CreateRecordReaderFromReader() with nil source, want error")
+	}
+}
+
+func TestRecordReader_Close(t *testing.T) {
+	r, err := CreateRecordReader("testdata/multiSection/v3_records.txt", false)
+	if err != nil {
+		t.Errorf("CreateRecordReader() error = %v", err)
+		return
+	}
+	ch := r.Iter()
+	<-ch
+	err = r.Close()
+	if err != nil {
+		t.Errorf("RecordReader.Close() error = %v", err)
+	}
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Errorf("RecordReader.Iter() sent a record after Close")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("RecordReader.Iter() did not stop after Close")
+	}
+	if _, err = r.buffer.closer.(*os.File).Stat(); err == nil {
+		t.Errorf("RecordReader.Close() did not close the file")
+	}
+	if err = r.Close(); err != nil {
+		t.Errorf("second RecordReader.Close() error = %v", err)
+	}
+	if r.Err != nil {
+		t.Errorf("RecordReader.Err = %v after Close", r.Err)
+	}
+}
+
+func TestRecordReader_IterContext(t *testing.T) {
+	tests := []struct {
+		name  string
+		lossy bool
+	}{
+		{name: "Iter", lossy: false},
+		{name: "IterLossy", lossy: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A followed file never ends, only the cancel can stop the iteration.
+			filename := filepath.Join(t.TempDir(), "modsec_audit.log")
+			appendTestFile(t, filename, followTestRecord("00000001"))
+			r, err := CreateFollowRecordReader(filename, false, false)
+			if err != nil {
+				t.Errorf("CreateFollowRecordReader() error = %v", err)
+				return
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stopped := make(chan int)
+			go func() {
+				count := 0
+				if tt.lossy {
+					for range r.IterLossyContext(ctx) {
+						count++
+					}
+				} else {
+					for range r.IterContext(ctx) {
+						count++
+					}
+				}
+				stopped <- count
+			}()
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+			select {
+			case count := <-stopped:
+				if count != 1 {
+					t.Errorf("iteration returned %d records, want 1", count)
+				}
+			case <-time.After(5 * time.Second):
+				t.Errorf("iteration did not stop after cancel")
+				return
+			}
+			if r.Err != context.Canceled {
+				t.Errorf("RecordReader.Err = %v, want %v", r.Err, context.Canceled)
+			}
+			if !r.isClosed() {
+				t.Errorf("RecordReader is not closed after cancel")
+			}
+		})
+	}
+}
+
+func TestRecordReader_SetRawLineEndings(t *testing.T) {
+	payload, err := os.ReadFile("testdata/multiSection/full_record.txt")
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v", err)
+	}
+	text := string(payload)
+	lines := strings.SplitAfter(text, "\n")
+	for i := range lines {
+		if i%2 == 0 {
+			lines[i] = strings.Replace(lines[i], "\n", "\r\n", 1)
+		}
+	}
+	tests := []struct {
+		name string
+		text string
+		raw  bool
+	}{
+		{name: "CRLF", text: strings.ReplaceAll(text, "\n", "\r\n")},
+		{name: "CRLF with raw line endings", text: strings.ReplaceAll(text, "\n", "\r\n"), raw: true},
+		{name: "Mixed line endings", text: strings.Join(lines, "")},
+		{name: "Mixed line endings with raw line endings", text: strings.Join(lines, ""), raw: true},
+	}
+	want, err := CreateRecordReaderFromReader(strings.NewReader(text), "test", false)
+	if err != nil {
+		t.Fatalf("CreateRecordReaderFromReader() error = %v", err)
+	}
+	wantRecord, err := want.Next(&strings.Builder{})
+	if err != nil {
+		t.Fatalf("RecordReader.Next() error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := CreateRecordReaderFromReader(strings.NewReader(tt.text), "test", false)
+			if err != nil {
+				t.Fatalf("CreateRecordReaderFromReader() error = %v", err)
+			}
+			r.SetRawLineEndings(tt.raw)
+			historyBuffer := &strings.Builder{}
+			record, err := r.Next(historyBuffer)
+			if err != nil {
+				t.Fatalf("RecordReader.Next() error = %v", err)
+			}
+			if record.ResumePosition.Offset != int64(len(tt.text)) {
+				t.Errorf("Record.ResumePosition = %v, want the end of the log at %d", record.ResumePosition, len(tt.text))
+			}
+			record.ResumePosition = wantRecord.ResumePosition
+			if tt.raw {
+				// The body of Section K keeps the original line breaks.
+				rule := record.MatchedRulesInformation.Rules[0]
+				wantRule := wantRecord.MatchedRulesInformation.Rules[0].Raw
+				if strings.Contains(tt.text, wantRule+"\r\n") {
+					wantRule += "\r"
+				}
+				if rule.Raw != wantRule {
+					t.Errorf("MatchedRule.Raw = %q, want %q", rule.Raw, wantRule)
+				}
+				rule.Raw = wantRecord.MatchedRulesInformation.Rules[0].Raw
+			}
+			if !reflect.DeepEqual(record, wantRecord) {
+				t.Errorf("RecordReader.Next() = %v, want %v", record, wantRecord)
+			}
+			wantRaw := text
+			if tt.raw {
+				wantRaw = tt.text
+			}
+			if historyBuffer.String() != wantRaw {
+				t.Errorf("history = %q, want %q", historyBuffer.String(), wantRaw)
+			}
+		})
+	}
+}
+
+func TestRecordReader_SectionOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		reassembly int
+	}{
+		{name: "Sequential"},
+		{name: "Reassembly", reassembly: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// ModSecurity 2 writes the sections in the order A B C I F E H Z.
+			r, err := CreateRecordReader("testdata/multiSection/v2_section_order.txt", false)
+			if err != nil {
+				t.Fatalf("CreateRecordReader() error = %v", err)
+			}
+			r.SetReassembly(tt.reassembly)
+			record, err := r.Next(&strings.Builder{})
+			if err != nil {
+				t.Fatalf("RecordReader.Next() error = %v", err)
+			}
+			if record.RequestBody == nil || record.ReducedMultipartRequestBody == nil || record.ResponseHeader == nil ||
+				record.IntendedResponseBody == nil || record.AuditLogTrailer == nil {
+				t.Errorf("RecordReader.Next() = %+v, want Section C, I, F, E and H", record)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package modsecure
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+)
+
+// SetReassembly turns on reassembling records of a serial audit log whose sections are interleaved with the
+// sections of other records, as written by several web server workers into the same log. Records are
+// returned when their Section Z is read. A record which got no section within the last window sections of the
+// log is given up and returned as incomplete. A window of 0 turns reassembling off.
+// Position refers to the start of the oldest record which is not returned yet, so records may be returned again
+// after resuming with SkipTo.
+func (r *RecordReader) SetReassembly(window int) {
+	if window <= 0 {
+		r.reassembler = nil
+		return
+	}
+	r.reassembler = &reassembler{
+		window:   window,
+		partials: make(map[string]*partialRecord),
+	}
+}
+
+type reassembler struct {
+	window int
+	// sections counts the section definitions read.
+	sections int
+	partials map[string]*partialRecord
+	// open are the partials in the order of their first section.
+	open []*partialRecord
+	// ready are the complete and the given up records in the order they are returned.
+	ready []*partialRecord
+}
+
+// partialRecord collects the sections of one record.
+type partialRecord struct {
+	id          string
+	record      *Record
+	lastSection EStructure
+	lastSeen    int
+	start       Position
+	raw         strings.Builder
+	// err is the first error of the record, it is returned instead of the record.
+	err             error
+	recordSize      int
+	recordTruncated bool
+}
+
+// readReassembledRecord reads sections until a record is complete or given up.
+func (r *RecordReader) readReassembledRecord(historyBuffer *strings.Builder) (record *Record, err error) {
+	reader := r.buffer
+	reader.readRecordMutex.Lock()
+	defer reader.readRecordMutex.Unlock()
+	a := r.reassembler
+	for len(a.ready) == 0 {
+		if reader.IsFinished {
+			a.expire(reader, true)
+			if len(a.ready) == 0 {
+				return nil, ErrEndReached
+			}
+			break
+		}
+		err = a.readSection(reader)
+		if err != nil {
+			return nil, err
+		}
+	}
+	partial := a.ready[0]
+	a.ready = a.ready[1:]
+	historyBuffer.WriteString(partial.raw.String())
+	if partial.err != nil {
+		return nil, partial.err
+	}
+	return partial.record, nil
+}
+
+func (a *reassembler) hasPending() bool {
+	return len(a.ready) > 0 || len(a.open) > 0
+}
+
+// oldestStart returns the start of the oldest record which was not returned yet.
+func (a *reassembler) oldestStart() (start Position, ok bool) {
+	for _, partials := range [][]*partialRecord{a.ready, a.open} {
+		for _, partial := range partials {
+			if !ok || partial.start.Offset < start.Offset {
+				start = partial.start
+				ok = true
+			}
+		}
+	}
+	return start, ok
+}
+
+// readSection reads the next section and adds it to its record. Lines outside of sections are returned as error.
+func (a *reassembler) readSection(reader *readBuffer) (err error) {
+	line, err := reader.PeekLine()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if err == io.EOF && line == "" {
+		reader.IsFinished = true
+		return nil
+	}
+	if strings.TrimSpace(line) == "" {
+		reader.AcceptPeekedLine()
+		return nil
+	}
+	start := reader.position()
+	success, id, sectionType := reader.parseSectionDefinition(line)
+	if !success {
+		a.readStrayLines(reader, start)
+		return nil
+	}
+	a.sections++
+	partial := a.partials[id]
+	if partial != nil && sectionType == AuditHeader {
+		// The boundary ID was reused, the old record is given up.
+		a.finish(reader, partial, errors.New(fmt.Sprintf("Record %s is not complete, its boundary was reused", id)))
+		partial = nil
+	}
+	if partial == nil {
+		partial = a.openRecord(id, start)
+		if sectionType != AuditHeader {
+			// A section of a given up record or of a record which started before the log.
+			partial.record.Id = id
+			partial.err = reader.newParseError(newCategoryError(CategorySectionStart, "Invalid section start"), sectionType, CategoryUnknown, start, line)
+		}
+	}
+	reader.LastSegmentKey = partial.lastSection
+	reader.recordSize = partial.recordSize
+	reader.recordTruncated = partial.recordTruncated
+	reader.truncations = nil
+	sectionBuffer := &strings.Builder{}
+	err = partial.record.ReadSection(reader, sectionBuffer)
+	partial.lastSection = reader.LastSegmentKey
+	partial.recordSize = reader.recordSize
+	partial.recordTruncated = reader.recordTruncated
+	partial.lastSeen = a.sections
+	partial.raw.WriteString(sectionBuffer.String())
+	if err == errNotMyRecord {
+		// A section type was repeated. The section is left for a new record.
+		a.finish(reader, partial, errors.New(fmt.Sprintf("Record %s is not complete, section %s was repeated", id, sectionType.Key())))
+		return nil
+	}
+	if reader.Provenance {
+		partial.addSource(reader.source(start))
+	}
+	switch {
+	case err == ErrEndReached:
+		return nil
+	case err != nil && partial.err == nil:
+		partial.err = err
+	}
+	if sectionType == AuditLogFooter {
+		a.finish(reader, partial, nil)
+	}
+	a.expire(reader, false)
+	return nil
+}
+
+// readStrayLines reads lines up to the next section definition and returns them as error.
+func (a *reassembler) readStrayLines(reader *readBuffer, start Position) {
+	stray := &partialRecord{start: start}
+	for {
+		line, err := reader.PeekLine()
+		if (err != nil && line == "") || reader.isSectionDefinition(line) {
+			break
+		}
+		if reader.keepHistory(&stray.raw) {
+			reader.writeLine(&stray.raw, line)
+		}
+		reader.AcceptPeekedLine()
+		if err != nil {
+			break
+		}
+	}
+	stray.err = reader.newParseError(newCategoryError(CategorySectionStart, "Invalid section start"), NIL, CategoryUnknown, start, stray.raw.String())
+	a.ready = append(a.ready, stray)
+}
+
+// addSource adds the Source of a section to the record.
+func (p *partialRecord) addSource(section *Source) {
+	if p.record.Source == nil {
+		p.record.Source = section
+		return
+	}
+	p.record.Source.appendSource(section)
+}
+
+func (a *reassembler) openRecord(id string, start Position) (partial *partialRecord) {
+	partial = &partialRecord{
+		id:          id,
+		record:      &Record{},
+		lastSection: NIL,
+		lastSeen:    a.sections,
+		start:       start,
+	}
+	a.partials[id] = partial
+	a.open = append(a.open, partial)
+	return partial
+}
+
+// expire gives up the records which got no section within the window, or all records.
+func (a *reassembler) expire(reader *readBuffer, all bool) {
+	for _, partial := range append([]*partialRecord(nil), a.open...) {
+		if all || a.sections-partial.lastSeen > a.window {
+			a.finish(reader, partial, errors.New(fmt.Sprintf("Record %s is not complete, Section Z is missing", partial.id)))
+		}
+	}
+}
+
+// finish moves a record to the records which are ready. incomplete is the error of a record without Section Z.
+func (a *reassembler) finish(reader *readBuffer, partial *partialRecord, incomplete error) {
+	delete(a.partials, partial.id)
+	for i, elem := range a.open {
+		if elem == partial {
+			a.open = append(a.open[:i], a.open[i+1:]...)
+			break
+		}
+	}
+	if partial.err == nil && incomplete != nil {
+		partial.err = reader.newParseError(incomplete, partial.lastSection, CategoryIncompleteRecord, partial.start, partial.raw.String())
+	}
+	if partial.err == nil && reader.DecodeResponseBodies {
+		partial.record.decodeResponseBodies()
+	}
+	a.ready = append(a.ready, partial)
+}
@@ -0,0 +1,153 @@
+package modsecure
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func reassemblyTestSection(boundary string, key rune) string {
+	switch key {
+	case 'A':
+		return fmt.Sprintf("--%s-A--\n[09/Oct/2018:11:45:38 +0200] W7x4gn8AAQEAAEA1Bv4AAAAA 192.168.1.10 50314 192.168.1.1 80\n\n", boundary)
+	case 'B':
+		return fmt.Sprintf("--%s-B--\nGET / HTTP/1.1\nHost: example.com\n\n", boundary)
+	case 'F':
+		return fmt.Sprintf("--%s-F--\nHTTP/1.1 200 OK\n\n", boundary)
+	default:
+		return fmt.Sprintf("--%s-%c--\n\n", boundary, key)
+	}
+}
+
+func TestRecordReader_SetReassembly(t *testing.T) {
+	sections := []struct {
+		boundary string
+		key      rune
+	}{
+		{"00000001", 'A'}, {"00000002", 'A'}, {"00000001", 'B'}, {"00000002", 'B'}, {"00000002", 'Z'},
+		{"00000001", 'F'}, {"00000001", 'Z'}, {"00000003", 'A'}, {"00000003", 'B'},
+		{"00000004", 'A'}, {"00000004", 'B'}, {"00000004", 'F'}, {"00000004", 'Z'},
+	}
+	log := &strings.Builder{}
+	for _, elem := range sections {
+		log.WriteString(reassemblyTestSection(elem.boundary, elem.key))
+	}
+	log.WriteString("stray line\n")
+	log.WriteString(reassemblyTestSection("00000005", 'B'))
+	r, err := CreateRecordReaderFromReader(strings.NewReader(log.String()), "test", false)
+	if err != nil {
+		t.Fatalf("CreateRecordReaderFromReader() error = %v", err)
+	}
+	r.SetReassembly(3)
+	tests := []struct {
+		wantId       string
+		wantCategory EErrorCategory
+		wantRaw      string
+	}{
+		{
+			wantId:  "00000002",
+			wantRaw: reassemblyTestSection("00000002", 'A') + reassemblyTestSection("00000002", 'B') + reassemblyTestSection("00000002", 'Z'),
+		},
+		{
+			wantId:  "00000001",
+			wantRaw: reassemblyTestSection("00000001", 'A') + reassemblyTestSection("00000001", 'B') + reassemblyTestSection("00000001", 'F') + reassemblyTestSection("00000001", 'Z'),
+		},
+		{wantId: "00000004"},
+		{
+			wantCategory: CategoryIncompleteRecord,
+			wantRaw:      reassemblyTestSection("00000003", 'A') + reassemblyTestSection("00000003", 'B'),
+		},
+		{wantCategory: CategorySectionStart, wantRaw: "stray line\n"},
+		{wantCategory: CategorySectionStart, wantRaw: reassemblyTestSection("00000005", 'B')},
+	}
+	for i, tt := range tests {
+		historyBuffer := &strings.Builder{}
+		if !r.HasNext() {
+			t.Fatalf("Record %d: RecordReader.HasNext() = false", i)
+		}
+		record, err := r.Next(historyBuffer)
+		if tt.wantId != "" {
+			if err != nil || record.Id != tt.wantId {
+				t.Errorf("Record %d: RecordReader.Next() = %v, error = %v, want %s", i, record, err, tt.wantId)
+			}
+		} else if !errors.Is(err, tt.wantCategory) {
+			t.Errorf("Record %d: RecordReader.Next() error = %v, want %v", i, err, tt.wantCategory)
+		}
+		if tt.wantRaw != "" && historyBuffer.String() != tt.wantRaw {
+			t.Errorf("Record %d: history = %q, want %q", i, historyBuffer.String(), tt.wantRaw)
+		}
+		if i == 0 && record != nil && record.ResumePosition.Offset != 0 {
+			t.Errorf("Record %d: ResumePosition = %v, want the start of the open record 00000001", i, record.ResumePosition)
+		}
+	}
+	if _, err = r.Next(&strings.Builder{}); err != ErrEndReached || r.HasNext() {
+		t.Errorf("RecordReader.Next() error = %v, HasNext = %v, want the end", err, r.HasNext())
+	}
+}
+
+func TestRecordReader_SetReassemblyLossy(t *testing.T) {
+	log := reassemblyTestSection("00000001", 'A') + reassemblyTestSection("00000002", 'A') +
+		reassemblyTestSection("00000001", 'Z') + reassemblyTestSection("00000002", 'Z')
+	tests := []struct {
+		name        string
+		window      int
+		wantRecords int
+	}{
+		{
+			name:        "Without reassembly",
+			window:      0,
+			wantRecords: 0,
+		},
+		{
+			name:        "With reassembly",
+			window:      10,
+			wantRecords: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := CreateRecordReaderFromReader(strings.NewReader(log), "test", false)
+			if err != nil {
+				t.Fatalf("CreateRecordReaderFromReader() error = %v", err)
+			}
+			r.SetReassembly(tt.window)
+			records := 0
+			for _, item := range readAllLossy(r) {
+				if item.Record != nil {
+					records++
+				}
+			}
+			if records != tt.wantRecords {
+				t.Errorf("RecordReader.IterLossy() returned %d records, want %d", records, tt.wantRecords)
+			}
+		})
+	}
+}
+
+func TestRecordReader_SetReassemblyFailures(t *testing.T) {
+	for _, filename := range []string{"testdata/multiSection/full_record.txt", "testdata/multiSection/v2_section_order.txt"} {
+		t.Run(filename, func(t *testing.T) {
+			r, err := CreateRecordReader(filename, false)
+			if err != nil {
+				t.Fatalf("CreateRecordReader() error = %v", err)
+			}
+			r.SetReassembly(10)
+			records, failures := 0, 0
+			for item := range r.IterLossy() {
+				if item.Record != nil {
+					records++
+				} else {
+					failures++
+					t.Errorf("RecordReader.IterLossy() failure = %v, raw %q", item.Err, item.Raw)
+				}
+			}
+			if records != 1 || failures != 0 {
+				t.Errorf("RecordReader.IterLossy() returned %d records and %d failures, want 1 and 0", records, failures)
+			}
+			if r.Err != nil {
+				t.Errorf("RecordReader.Err = %v, want nil", r.Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package modsecure
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"github.com/pkg/errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+)
+
+// decodeBody decodes a request body according to its Content-Type. Bodies of an unknown type are not
+// decoded and nil is returned. A body which does not match its Content-Type is returned with DecodeError set.
+func decodeBody(contentType string, raw string) (decoded *DecodedBody) {
+	if contentType == "" || raw == "" {
+		return nil
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	decoded = &DecodedBody{
+		ContentType: mediaType,
+	}
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		decoded.Form = parseUrlEncodedFields(raw)
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		decoded.JSON, err = decodeJSONBody(raw)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		decoded.XML, err = decodeXMLBody(raw)
+	case strings.HasPrefix(mediaType, "multipart/"):
+		decoded.Multipart, err = decodeMultipartBody(raw, params["boundary"])
+	default:
+		return nil
+	}
+	if err != nil {
+		decoded.DecodeError = err.Error()
+	}
+	return decoded
+}
+
+func decodeJSONBody(raw string) (tree interface{}, err error) {
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+	err = decoder.Decode(&tree)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Invalid JSON body")
+	}
+	return tree, nil
+}
+
+func decodeXMLBody(raw string) (root *XMLNode, err error) {
+	decoder := xml.NewDecoder(strings.NewReader(raw))
+	decoder.Strict = false
+	stack := make([]*XMLNode, 0, 8)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return root, errors.WithMessage(err, "Invalid XML body")
+		}
+		switch elem := token.(type) {
+		case xml.StartElement:
+			node := &XMLNode{
+				Name: elem.Name.Local,
+			}
+			for _, attr := range elem.Attr {
+				node.Attributes = append(node.Attributes, XMLAttribute{Name: attr.Name.Local, Value: attr.Value})
+			}
+			if len(stack) == 0 {
+				if root != nil {
+					return root, errors.New("Invalid XML body, more than one root element")
+				}
+				root = node
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, node)
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Text += strings.TrimSpace(string(elem))
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("Invalid XML body, no root element")
+	}
+	return root, nil
+}
+
+func decodeMultipartBody(raw string, boundary string) (parts []*MultipartPart, err error) {
+	if boundary == "" {
+		return nil, errors.New("Invalid multipart body, boundary is missing")
+	}
+	// The log lines lost their line ending, the closing boundary needs one.
+	if !strings.HasSuffix(raw, "\n") {
+		raw = raw + "\n"
+	}
+	reader := multipart.NewReader(strings.NewReader(raw), boundary)
+	parts = make([]*MultipartPart, 0, 2)
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return parts, errors.WithMessage(err, "Invalid multipart body")
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return parts, errors.WithMessage(err, "Invalid multipart body")
+		}
+		parts = append(parts, &MultipartPart{
+			Name:        part.FormName(),
+			FileName:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Value:       string(content),
+		})
+	}
+	return parts, nil
+}
+
+// decodeRequestBody decodes Section C with the Content-Type of Section B. raw is the exact text of the body, if it
+// is empty the lines of the body are joined.
+func (r *Record) decodeRequestBody(raw string) {
+	if r.RequestHeader == nil || r.RequestBody == nil {
+		return
+	}
+	if raw == "" {
+		raw = strings.Join(r.RequestBody, "\n")
+	}
+	contentType := r.RequestHeader.Header.Get("Content-Type")
+	r.DecodedRequestBody = decodeBody(contentType, raw)
+}
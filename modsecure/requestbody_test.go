@@ -0,0 +1,118 @@
+package modsecure
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_decodeBody(t *testing.T) {
+	type args struct {
+		contentType string
+		raw         string
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantDecoded *DecodedBody
+	}{
+		{
+			name: "Url-encoded form",
+			args: args{
+				contentType: "application/x-www-form-urlencoded; charset=UTF-8",
+				raw:         "q=1%27+or+1%3D1&q=2",
+			},
+			wantDecoded: &DecodedBody{
+				ContentType: "application/x-www-form-urlencoded",
+				Form: []*FormField{
+					{Name: "q", Value: "1' or 1=1"},
+					{Name: "q", Value: "2"},
+				},
+			},
+		},
+		{
+			name: "JSON",
+			args: args{
+				contentType: "application/vnd.api+json",
+				raw:         "{\"user\": {\"id\": 12345678901234567890,\n\"tags\": [\"a\"]}}",
+			},
+			wantDecoded: &DecodedBody{
+				ContentType: "application/vnd.api+json",
+				JSON: map[string]interface{}{
+					"user": map[string]interface{}{
+						"id":   json.Number("12345678901234567890"),
+						"tags": []interface{}{"a"},
+					},
+				},
+			},
+		},
+		{
+			name: "Broken JSON",
+			args: args{
+				contentType: "application/json",
+				raw:         "{\"user\":",
+			},
+			wantDecoded: &DecodedBody{
+				ContentType: "application/json",
+				DecodeError: "Invalid JSON body: unexpected EOF",
+			},
+		},
+		{
+			name: "XML",
+			args: args{
+				contentType: "text/xml",
+				raw:         "<?xml version=\"1.0\"?>\n<login user=\"admin\">\n  <password>secret</password>\n</login>",
+			},
+			wantDecoded: &DecodedBody{
+				ContentType: "text/xml",
+				XML: &XMLNode{
+					Name:       "login",
+					Attributes: []XMLAttribute{{Name: "user", Value: "admin"}},
+					Children: []*XMLNode{
+						{Name: "password", Text: "secret"},
+					},
+				},
+			},
+		},
+		{
+			name: "Multipart",
+			args: args{
+				contentType: "multipart/form-data; boundary=xyz",
+				raw: "--xyz\r\nContent-Disposition: form-data; name=\"name\"\r\n\r\nDoe\r\n" +
+					"--xyz\r\nContent-Disposition: form-data; name=\"upload\"; filename=\"shell.php\"\r\nContent-Type: application/x-php\r\n\r\n<?php system($_GET['c']); ?>\r\n" +
+					"--xyz--\r",
+			},
+			wantDecoded: &DecodedBody{
+				ContentType: "multipart/form-data",
+				Multipart: []*MultipartPart{
+					{Name: "name", Value: "Doe"},
+					{Name: "upload", FileName: "shell.php", ContentType: "application/x-php", Value: "<?php system($_GET['c']); ?>"},
+				},
+			},
+		},
+		{
+			name: "Unknown content type",
+			args: args{
+				contentType: "application/octet-stream",
+				raw:         "binary",
+			},
+			wantDecoded: nil,
+		},
+		{
+			name: "No content type",
+			args: args{
+				contentType: "",
+				raw:         "a=b",
+			},
+			wantDecoded: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotDecoded := decodeBody(tt.args.contentType, tt.args.raw)
+			if !reflect.DeepEqual(gotDecoded, tt.wantDecoded) {
+				t.Errorf("decodeBody() =\n is   %#v,\n want %#v", gotDecoded, tt.wantDecoded)
+			}
+		})
+	}
+}
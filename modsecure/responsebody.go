@@ -0,0 +1,120 @@
+package modsecure
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"net/http/httputil"
+	"strings"
+)
+
+const (
+	responseBodyDechunked = "Dechunked"
+)
+
+// decodeResponseBody removes the transfer encoding and the content encoding of a logged response body.
+// dechunked tells that ModSecurity already removed the chunked transfer encoding before logging the body.
+// Decoded is only set if every encoding could be removed, otherwise Body keeps the raw body.
+func decodeResponseBody(raw string, contentEncoding string, transferEncoding string, dechunked bool) (decoded *DecodedResponseBody) {
+	decoded = &DecodedResponseBody{
+		Body: raw,
+	}
+	body := []byte(raw)
+	var err error
+	if !dechunked {
+		for _, encoding := range splitEncodings(transferEncoding) {
+			if encoding != "chunked" {
+				continue
+			}
+			body, err = io.ReadAll(httputil.NewChunkedReader(bytes.NewReader(body)))
+			if err != nil {
+				decoded.Error = errors.WithMessage(err, "Failed to dechunk body").Error()
+				return decoded
+			}
+		}
+	}
+	// Encodings are listed in the order they were applied.
+	encodings := splitEncodings(contentEncoding)
+	for i := len(encodings) - 1; i >= 0; i-- {
+		body, err = decodeContentEncoding(body, encodings[i])
+		if err != nil {
+			decoded.Error = err.Error()
+			return decoded
+		}
+	}
+	decoded.Body = string(body)
+	decoded.Decoded = true
+	return decoded
+}
+
+func decodeContentEncoding(body []byte, encoding string) (decodedBody []byte, err error) {
+	var reader io.Reader
+	switch encoding {
+	case "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// Most servers send a zlib stream, some send a raw deflate stream.
+		reader, err = zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			reader, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("Unsupported Content-Encoding: %s", encoding))
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("Failed to decode %s body", encoding))
+	}
+	decodedBody, err = io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("Failed to decode %s body", encoding))
+	}
+	return decodedBody, nil
+}
+
+func splitEncodings(header string) (encodings []string) {
+	encodings = make([]string, 0, 1)
+	for _, elem := range strings.Split(header, ",") {
+		elem = strings.ToLower(strings.TrimSpace(elem))
+		if elem != "" {
+			encodings = append(encodings, elem)
+		}
+	}
+	return encodings
+}
+
+// decodeResponseBodies decodes Section E and G with the encodings of the response headers in Section F.
+// Bodies without any encoding are left alone. The exact text of the bodies is used if the reader kept it.
+func (r *Record) decodeResponseBodies() {
+	defer func() {
+		r.rawIntendedResponseBody, r.rawResponseBody = "", ""
+	}()
+	if r.ResponseHeader == nil {
+		return
+	}
+	contentEncoding := strings.Join(r.ResponseHeader.Header.Values("Content-Encoding"), ",")
+	transferEncoding := strings.Join(r.ResponseHeader.Header.Values("Transfer-Encoding"), ",")
+	if contentEncoding == "" && transferEncoding == "" {
+		return
+	}
+	dechunked := r.AuditLogTrailer != nil && r.AuditLogTrailer.ResponseBodyTransformed == responseBodyDechunked
+	if r.IntendedResponseBody != nil {
+		raw := r.rawIntendedResponseBody
+		if raw == "" {
+			raw = strings.Join(r.IntendedResponseBody.Body, "\n")
+		}
+		r.IntendedResponseBody.Decoded = decodeResponseBody(raw, contentEncoding, transferEncoding, dechunked)
+	}
+	if r.ResponseBody != nil {
+		raw := r.rawResponseBody
+		if raw == "" {
+			raw = strings.Join(r.ResponseBody, "\n")
+		}
+		r.DecodedResponseBody = decodeResponseBody(raw, contentEncoding, transferEncoding, dechunked)
+	}
+}
@@ -0,0 +1,171 @@
+package modsecure
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func gzipString(payload string) string {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	writer.Write([]byte(payload))
+	writer.Close()
+	return buffer.String()
+}
+
+func zlibString(payload string) string {
+	var buffer bytes.Buffer
+	writer := zlib.NewWriter(&buffer)
+	writer.Write([]byte(payload))
+	writer.Close()
+	return buffer.String()
+}
+
+func Test_decodeResponseBody(t *testing.T) {
+	type args struct {
+		raw              string
+		contentEncoding  string
+		transferEncoding string
+		dechunked        bool
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantDecoded *DecodedResponseBody
+	}{
+		{
+			name: "Gzip",
+			args: args{
+				raw:             gzipString("<html>hello</html>"),
+				contentEncoding: "gzip",
+			},
+			wantDecoded: &DecodedResponseBody{Body: "<html>hello</html>", Decoded: true},
+		},
+		{
+			name: "Deflate",
+			args: args{
+				raw:             zlibString("hello"),
+				contentEncoding: "Deflate",
+			},
+			wantDecoded: &DecodedResponseBody{Body: "hello", Decoded: true},
+		},
+		{
+			name: "Chunked",
+			args: args{
+				raw:              "5\r\nhello\r\n6\r\n world\r\n0\r\n\r\n",
+				transferEncoding: "chunked",
+			},
+			wantDecoded: &DecodedResponseBody{Body: "hello world", Decoded: true},
+		},
+		{
+			name: "Already dechunked by ModSecurity",
+			args: args{
+				raw:              "hello world",
+				transferEncoding: "chunked",
+				dechunked:        true,
+			},
+			wantDecoded: &DecodedResponseBody{Body: "hello world", Decoded: true},
+		},
+		{
+			name: "Chunked gzip",
+			args: args{
+				raw:              "e\r\n" + gzipString("")[:14] + "\r\n" + "6\r\n" + gzipString("")[14:] + "\r\n0\r\n\r\n",
+				contentEncoding:  "gzip",
+				transferEncoding: "chunked",
+			},
+			wantDecoded: &DecodedResponseBody{Body: "", Decoded: true},
+		},
+		{
+			name: "Broken gzip",
+			args: args{
+				raw:             "this is not gzip data",
+				contentEncoding: "gzip",
+			},
+			wantDecoded: &DecodedResponseBody{Body: "this is not gzip data", Error: "Failed to decode gzip body: gzip: invalid header"},
+		},
+		{
+			name: "Unsupported encoding",
+			args: args{
+				raw:             "abc",
+				contentEncoding: "br",
+			},
+			wantDecoded: &DecodedResponseBody{Body: "abc", Error: "Unsupported Content-Encoding: br"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotDecoded := decodeResponseBody(tt.args.raw, tt.args.contentEncoding, tt.args.transferEncoding, tt.args.dechunked)
+			if !reflect.DeepEqual(gotDecoded, tt.wantDecoded) {
+				t.Errorf("decodeResponseBody() =\n is   %#v,\n want %#v", gotDecoded, tt.wantDecoded)
+			}
+		})
+	}
+}
+
+func TestRecordReader_SetDecodeResponseBodies(t *testing.T) {
+	section := func(name byte, body string) string {
+		return "--5a1b2c3d-" + string(name) + "--\n" + body + "\n"
+	}
+	auditHeader := section('A', "[08/Oct/2018:00:00:01 +0200] W7qB4cCoFIQAAHtbutUAAAFI 92.38.32.36 36354 192.168.20.132 443\n") +
+		section('B', "GET / HTTP/1.1\nHost: example.org\n") +
+		section('C', "")
+	tests := []struct {
+		name         string
+		log          string
+		debugSkipper bool
+		wantBody     string
+	}{
+		{
+			name: "Section E after F",
+			log: auditHeader +
+				section('F', "HTTP/1.1 200 OK\nTransfer-Encoding: chunked\n") +
+				section('E', "5\r\nhello\r\n0\r\n\r\n") +
+				section('H', "Engine-Mode: \"ENABLED\"\n") +
+				section('Z', ""),
+			wantBody: "hello",
+		},
+		{
+			name: "Comment lines in the body",
+			log: auditHeader +
+				section('F', "HTTP/1.1 200 OK\nTransfer-Encoding: chunked\n") +
+				section('E', "7\r\n#hello\n\r\n0\r\n\r\n") +
+				section('H', "Engine-Mode: \"ENABLED\"\n") +
+				section('Z', ""),
+			debugSkipper: true,
+			wantBody:     "#hello\n",
+		},
+		{
+			name: "Gzip",
+			log: auditHeader +
+				section('F', "HTTP/1.1 200 OK\nContent-Encoding: gzip\n") +
+				section('E', gzipString("<html>\r\nhello\n\n</html>\n")) +
+				section('H', "Engine-Mode: \"ENABLED\"\n") +
+				section('Z', ""),
+			wantBody: "<html>\r\nhello\n\n</html>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := CreateRecordReaderFromReader(strings.NewReader(tt.log), "test", tt.debugSkipper)
+			if err != nil {
+				t.Fatalf("CreateRecordReaderFromReader() error = %v", err)
+			}
+			r.SetDecodeResponseBodies(true)
+			record, err := r.Next(&strings.Builder{})
+			if err != nil {
+				t.Fatalf("RecordReader.Next() error = %v", err)
+			}
+			if record.IntendedResponseBody == nil || record.AuditLogTrailer == nil {
+				t.Fatalf("RecordReader.Next() = %+v, want Section E and H in the record", record)
+			}
+			want := &DecodedResponseBody{Body: tt.wantBody, Decoded: true}
+			if !reflect.DeepEqual(record.IntendedResponseBody.Decoded, want) {
+				t.Errorf("SectionEIntendedResponseBody.Decoded = %#v, want %#v", record.IntendedResponseBody.Decoded, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package modsecure
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type ESeverity int
+
+const (
+	SeverityUnknown   ESeverity = -1
+	SeverityEmergency ESeverity = iota - 1
+	SeverityAlert
+	SeverityCritical
+	SeverityError
+	SeverityWarning
+	SeverityNotice
+	SeverityInfo
+	SeverityDebug
+)
+
+var (
+	severityNames = map[ESeverity]string{
+		SeverityEmergency: "EMERGENCY",
+		SeverityAlert:     "ALERT",
+		SeverityCritical:  "CRITICAL",
+		SeverityError:     "ERROR",
+		SeverityWarning:   "WARNING",
+		SeverityNotice:    "NOTICE",
+		SeverityInfo:      "INFO",
+		SeverityDebug:     "DEBUG",
+	}
+	// finds the first metadata entry of a message: ` [file "`
+	ruleMatchMetadataStartRegex = regexp.MustCompile(`(?:^|\s)\[[a-z_]+\s"`)
+	// parses: "Matched Data: union select found within ARGS:q: 1 union select 2"
+	matchedDataRegex = regexp.MustCompile(`^Matched Data: (.*?) found within (\S+?)(?:: (.*))?$`)
+)
+
+// ParseSeverity accepts the name ("CRITICAL") as well as the number ("2") of a severity.
+func ParseSeverity(value string) (severity ESeverity, err error) {
+	if number, err := strconv.Atoi(value); err == nil {
+		severity = ESeverity(number)
+		if _, ok := severityNames[severity]; ok {
+			return severity, nil
+		}
+		return SeverityUnknown, errors.New(fmt.Sprintf("Invalid severity: %s", value))
+	}
+	for key, name := range severityNames {
+		if strings.EqualFold(name, value) {
+			return key, nil
+		}
+	}
+	return SeverityUnknown, errors.New(fmt.Sprintf("Invalid severity: %s", value))
+}
+
+func (s ESeverity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return ""
+}
+
+func (s ESeverity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *ESeverity) UnmarshalText(text []byte) (err error) {
+	if len(text) == 0 {
+		*s = SeverityUnknown
+		return nil
+	}
+	*s, err = ParseSeverity(string(text))
+	return err
+}
+
+// parseRuleMatches parses the messages of Section H. A message which can not be parsed is left out, it is still
+// kept as written in the Messages of the trailer.
+func parseRuleMatches(messages []string) (matches []*RuleMatch) {
+	matches = make([]*RuleMatch, 0, len(messages))
+	for _, elem := range messages {
+		match, err := parseRuleMatch(elem)
+		if err != nil {
+			continue
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+// parseRuleMatch parses the value of a "Message:" trailer line, e.g.
+// `Warning. Pattern match "union" at ARGS:q. [file "/rules/942.conf"] [line "12"] [id "942100"] [tag "sqli"]`
+func parseRuleMatch(message string) (match *RuleMatch, err error) {
+	match = &RuleMatch{
+		Severity: SeverityUnknown,
+	}
+	description := message
+	metadata := ""
+	if location := ruleMatchMetadataStartRegex.FindStringIndex(message); location != nil {
+		description = message[:location[0]]
+		metadata = message[location[0]:]
+	}
+	description = strings.TrimSpace(description)
+	splitterated := strings.SplitN(description, ". ", 2)
+	if len(splitterated) == 2 {
+		match.Disposition = splitterated[0]
+		match.Description = splitterated[1]
+	} else {
+		match.Description = description
+	}
+	entries, err := parseBracketedMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name, value := entry[0], entry[1]
+		switch name {
+		case "file":
+			match.File = value
+		case "line":
+			match.Line, err = strconv.Atoi(value)
+		case "id":
+			match.ID = value
+		case "rev":
+			match.Rev = value
+		case "msg":
+			match.Msg = value
+		case "data":
+			match.Data = value
+		case "severity":
+			match.Severity, err = ParseSeverity(value)
+		case "ver":
+			match.Version = value
+		case "maturity":
+			match.Maturity, err = strconv.Atoi(value)
+		case "accuracy":
+			match.Accuracy, err = strconv.Atoi(value)
+		case "tag":
+			match.Tags = append(match.Tags, value)
+		default:
+			if match.Other == nil {
+				match.Other = make(map[string][]string)
+			}
+			match.Other[name] = append(match.Other[name], value)
+		}
+		if err != nil {
+			// A broken value is kept as written, the field is left unset.
+			if match.Other == nil {
+				match.Other = make(map[string][]string)
+			}
+			match.Other[name] = append(match.Other[name], value)
+			err = nil
+		}
+	}
+	if parsedData := matchedDataRegex.FindStringSubmatch(match.Data); parsedData != nil {
+		match.MatchedData = parsedData[1]
+		match.MatchedVariable = parsedData[2]
+		match.MatchedValue = parsedData[3]
+	}
+	return match, nil
+}
+
+// parseBracketedMetadata splits `[file "a.conf"] [line 12] [msg "say \"hi\" [x]"]` into name and value pairs.
+// Only \", \\ and \] are unescaped, other escapes like \x22 for non-printable bytes are kept as written.
+// Quoted values may contain escaped quotes and brackets.
+func parseBracketedMetadata(metadata string) (entries [][2]string, err error) {
+	entries = make([][2]string, 0, 8)
+	rest := strings.TrimSpace(metadata)
+	for len(rest) > 0 {
+		if rest[0] != '[' {
+			// Free text between or after the entries, e.g. "ModSecurity: Warning." in Apache-Error lines.
+			next := strings.Index(rest, " [")
+			if next < 0 {
+				break
+			}
+			rest = strings.TrimSpace(rest[next:])
+			continue
+		}
+		nameEnd := strings.IndexAny(rest, " ]")
+		if nameEnd < 0 {
+			return nil, errors.New(fmt.Sprintf("Unterminated metadata: %s", rest))
+		}
+		name := rest[1:nameEnd]
+		rest = strings.TrimLeft(rest[nameEnd:], " ")
+		var value strings.Builder
+		if strings.HasPrefix(rest, "\"") {
+			i := 1
+			for ; i < len(rest); i++ {
+				if rest[i] == '\\' && i+1 < len(rest) && strings.IndexByte(`"\\]`, rest[i+1]) >= 0 {
+					i++
+					value.WriteByte(rest[i])
+					continue
+				}
+				if rest[i] == '"' {
+					break
+				}
+				value.WriteByte(rest[i])
+			}
+			if i >= len(rest) {
+				return nil, errors.New(fmt.Sprintf("Unterminated quote in metadata %s", name))
+			}
+			rest = strings.TrimLeft(rest[i+1:], " ")
+		} else {
+			valueEnd := strings.IndexByte(rest, ']')
+			if valueEnd < 0 {
+				return nil, errors.New(fmt.Sprintf("Unterminated metadata %s", name))
+			}
+			value.WriteString(strings.TrimSpace(rest[:valueEnd]))
+			rest = rest[valueEnd:]
+		}
+		if !strings.HasPrefix(rest, "]") {
+			return nil, errors.New(fmt.Sprintf("Unterminated metadata %s", name))
+		}
+		entries = append(entries, [2]string{name, value.String()})
+		rest = strings.TrimSpace(rest[1:])
+	}
+	return entries, nil
+}
@@ -0,0 +1,193 @@
+package modsecure
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseRuleMatch(t *testing.T) {
+	type args struct {
+		message string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		wantMatch *RuleMatch
+		wantErr   bool
+	}{
+		{
+			name: "CRS message",
+			args: args{
+				message: `Warning. detected SQLi using libinjection with fingerprint 's&sos' [file "/etc/crs/REQUEST-942-APPLICATION-ATTACK-SQLI.conf"] [line "68"] [id "942100"] [rev "1"] [msg "SQL Injection Attack Detected via libinjection"] [data "Matched Data: s&sos found within ARGS:id: 1' or '1'='1"] [severity "CRITICAL"] [ver "OWASP_CRS/3.0.0"] [maturity "1"] [accuracy "8"] [tag "application-multi"] [tag "attack-sqli"]`,
+			},
+			wantMatch: &RuleMatch{
+				Disposition:     "Warning",
+				Description:     "detected SQLi using libinjection with fingerprint 's&sos'",
+				File:            "/etc/crs/REQUEST-942-APPLICATION-ATTACK-SQLI.conf",
+				Line:            68,
+				ID:              "942100",
+				Rev:             "1",
+				Msg:             "SQL Injection Attack Detected via libinjection",
+				Data:            "Matched Data: s&sos found within ARGS:id: 1' or '1'='1",
+				Severity:        SeverityCritical,
+				Version:         "OWASP_CRS/3.0.0",
+				Maturity:        1,
+				Accuracy:        8,
+				Tags:            []string{"application-multi", "attack-sqli"},
+				MatchedData:     "s&sos",
+				MatchedVariable: "ARGS:id",
+				MatchedValue:    "1' or '1'='1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Escaped quotes and brackets",
+			args: args{
+				message: `Access denied with code 403 (phase 2). Pattern match "[a-z]" at ARGS:q. [id "1"] [msg "say \"hi\" [now]"] [data "a\\b"] [severity "2"] [hostname "example.org"]`,
+			},
+			wantMatch: &RuleMatch{
+				Disposition: "Access denied with code 403 (phase 2)",
+				Description: `Pattern match "[a-z]" at ARGS:q.`,
+				ID:          "1",
+				Msg:         `say "hi" [now]`,
+				Data:        `a\b`,
+				Severity:    SeverityCritical,
+				Other:       map[string][]string{"hostname": {"example.org"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Message without metadata",
+			args: args{
+				message: "Warning. Something happened.",
+			},
+			wantMatch: &RuleMatch{
+				Disposition: "Warning",
+				Description: "Something happened.",
+				Severity:    SeverityUnknown,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Unterminated quote",
+			args: args{
+				message: `Warning. x [msg "broken]`,
+			},
+			wantMatch: nil,
+			wantErr:   true,
+		},
+		{
+			name: "Invalid severity",
+			args: args{
+				message: `Warning. x [severity "LOUD"]`,
+			},
+			wantMatch: &RuleMatch{
+				Disposition: "Warning",
+				Description: "x",
+				Severity:    SeverityUnknown,
+				Other:       map[string][]string{"severity": {"LOUD"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid maturity and accuracy",
+			args: args{
+				message: `Warning. x [id "1"] [maturity ""] [accuracy "high"]`,
+			},
+			wantMatch: &RuleMatch{
+				Disposition: "Warning",
+				Description: "x",
+				ID:          "1",
+				Severity:    SeverityUnknown,
+				Other:       map[string][]string{"maturity": {""}, "accuracy": {"high"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Hex escaped bytes",
+			args: args{
+				message: `Warning. x [data "Matched Data: \x22abc found within ARGS:q: \x0a\x22abc"]`,
+			},
+			wantMatch: &RuleMatch{
+				Disposition:     "Warning",
+				Description:     "x",
+				Data:            `Matched Data: \x22abc found within ARGS:q: \x0a\x22abc`,
+				Severity:        SeverityUnknown,
+				MatchedData:     `\x22abc`,
+				MatchedVariable: "ARGS:q",
+				MatchedValue:    `\x0a\x22abc`,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotMatch, err := parseRuleMatch(tt.args.message)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseRuleMatch() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotMatch, tt.wantMatch) {
+				t.Errorf("parseRuleMatch() =\n is   %#v,\n want %#v", gotMatch, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func Test_parseRuleMatches(t *testing.T) {
+	messages := []string{
+		`Warning. Pattern match "a" at ARGS:q. [id "1"] [msg "broken]`,
+		`Warning. Pattern match "b" at ARGS:q. [id "2"]`,
+	}
+	gotMatches := parseRuleMatches(messages)
+	if len(gotMatches) != 1 || gotMatches[0].ID != "2" {
+		t.Errorf("parseRuleMatches() = %#v, want only the match of rule 2", gotMatches)
+	}
+}
+
+func TestRecordReader_BrokenRuleMatch(t *testing.T) {
+	log := "--1a2b3c4d-A--\n[08/Oct/2018:00:00:01 +0200] W7qB4cCoFIQAAHtbutUAAAFI 92.38.32.36 36354 192.168.20.132 443\n" +
+		"--1a2b3c4d-B--\nGET / HTTP/1.1\nHost: example.org\n\n" +
+		"--1a2b3c4d-H--\nMessage: Warning. Pattern match \"a\" at ARGS:q. [id \"1\"] [msg \"broken]\n\n" +
+		"--1a2b3c4d-Z--\n\n"
+	r, err := CreateRecordReaderFromReader(strings.NewReader(log), "test", false)
+	if err != nil {
+		t.Fatalf("CreateRecordReaderFromReader() error = %v", err)
+	}
+	record, err := r.Next(&strings.Builder{})
+	if err != nil {
+		t.Fatalf("RecordReader.Next() error = %v", err)
+	}
+	if len(record.AuditLogTrailer.Messages) != 1 || len(record.RuleMatches) != 0 {
+		t.Errorf("Record = %v messages and %v rule matches, want the message without a rule match",
+			record.AuditLogTrailer.Messages, record.RuleMatches)
+	}
+}
+
+func TestESeverity_UnmarshalText(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		wantSeverity ESeverity
+		wantErr      bool
+	}{
+		{name: "Name", text: "WARNING", wantSeverity: SeverityWarning},
+		{name: "Number", text: "0", wantSeverity: SeverityEmergency},
+		{name: "Empty", text: "", wantSeverity: SeverityUnknown},
+		{name: "Out of range", text: "8", wantSeverity: SeverityUnknown, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotSeverity ESeverity
+			err := gotSeverity.UnmarshalText([]byte(tt.text))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ESeverity.UnmarshalText() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotSeverity != tt.wantSeverity {
+				t.Errorf("ESeverity.UnmarshalText() = %v, want %v", gotSeverity, tt.wantSeverity)
+			}
+		})
+	}
+}
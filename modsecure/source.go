@@ -0,0 +1,66 @@
+package modsecure
+
+// SetProvenance turns on noting the Source of every record: the file, the positions and the exact bytes of
+// the record, independent of SetRawLineEndings. The bytes of a record include the empty lines which follow it.
+// A reassembled record, see SetReassembly, spans from its first section to its Section Z and its bytes are the
+// ones of its own sections. Records of a concurrent audit log refer to their transaction file.
+func (r *RecordReader) SetProvenance(provenance bool) {
+	r.buffer.Provenance = provenance
+}
+
+// capture keeps the bytes of a line of size bytes, which is about to be counted into readOffset. A line which
+// was cut by the Limits can not be kept, the captured bytes start after it then.
+func (r *readBuffer) capture(line string, size int, truncated bool) {
+	if r.capturedOffset+int64(len(r.captured)) != r.readOffset || truncated {
+		// The reader was moved, e.g. by SkipTo, or the line is not complete.
+		r.captured = r.captured[:0]
+		r.capturedOffset = r.readOffset
+	}
+	if truncated {
+		r.capturedOffset += int64(size)
+		return
+	}
+	r.captured = append(r.captured, line...)
+}
+
+// discardCaptured drops the captured bytes before offset.
+func (r *readBuffer) discardCaptured(offset int64) {
+	count := offset - r.capturedOffset
+	if count <= 0 {
+		return
+	}
+	if count > int64(len(r.captured)) {
+		count = int64(len(r.captured))
+	}
+	r.captured = append(r.captured[:0], r.captured[count:]...)
+	r.capturedOffset += count
+}
+
+// source returns the Source of the part of the log which was read since start. Raw is only set if all of its
+// bytes were captured.
+func (r *readBuffer) source(start Position) (source *Source) {
+	end := r.position()
+	source = &Source{
+		File:        r.Name,
+		StartOffset: start.Offset,
+		EndOffset:   end.Offset,
+		StartLine:   start.Line + 1,
+		EndLine:     end.Line,
+	}
+	if start.Offset >= r.capturedOffset && end.Offset <= r.capturedOffset+int64(len(r.captured)) {
+		source.Raw = append([]byte(nil), r.captured[start.Offset-r.capturedOffset:end.Offset-r.capturedOffset]...)
+	}
+	r.discardCaptured(end.Offset)
+	return source
+}
+
+// appendSource adds the Source of a following part of the record to the one of the record.
+func (s *Source) appendSource(next *Source) {
+	s.EndOffset = next.EndOffset
+	s.EndLine = next.EndLine
+	if s.Raw == nil || next.Raw == nil {
+		s.Raw = nil
+		return
+	}
+	s.Raw = append(s.Raw, next.Raw...)
+}
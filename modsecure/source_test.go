@@ -0,0 +1,147 @@
+package modsecure
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecordReader_SetProvenance(t *testing.T) {
+	tests := []struct {
+		name       string
+		crlf       bool
+		limits     Limits
+		provenance bool
+		wantRaw    bool
+	}{
+		{
+			name:       "Without provenance",
+			provenance: false,
+		},
+		{
+			name:       "With provenance",
+			provenance: true,
+			wantRaw:    true,
+		},
+		{
+			name:       "CRLF",
+			crlf:       true,
+			provenance: true,
+			wantRaw:    true,
+		},
+		{
+			name:       "Truncated records",
+			limits:     Limits{MaxLineSize: 100, Truncate: true},
+			provenance: true,
+			wantRaw:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := writeTestLog(t, 10, 3)
+			payload, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatalf("os.ReadFile() error = %v", err)
+			}
+			if tt.crlf {
+				payload = []byte(strings.ReplaceAll(string(payload), "\n", "\r\n"))
+				if err = os.WriteFile(filename, payload, 0644); err != nil {
+					t.Fatalf("os.WriteFile() error = %v", err)
+				}
+			}
+			r, err := CreateRecordReader(filename, false)
+			if err != nil {
+				t.Fatalf("CreateRecordReader() error = %v", err)
+			}
+			r.SetLimits(tt.limits)
+			r.SetProvenance(tt.provenance)
+			records := 0
+			for record := range r.AllLossy() {
+				if record == nil {
+					continue
+				}
+				records++
+				source := record.Source
+				if !tt.provenance {
+					if source != nil {
+						t.Errorf("Record.Source = %v, want nil", source)
+					}
+					continue
+				}
+				if source == nil || source.File != filename || source.EndOffset != record.ResumePosition.Offset {
+					t.Fatalf("Record.Source = %+v, want the record up to %v", source, record.ResumePosition)
+				}
+				text := string(payload[source.StartOffset:source.EndOffset])
+				if !strings.HasPrefix(text, "--"+record.Id+"-A--") {
+					t.Errorf("Record %s: Source.StartOffset = %d, want the start of the record", record.Id, source.StartOffset)
+				}
+				if source.StartLine != strings.Count(string(payload[:source.StartOffset]), "\n")+1 || source.EndLine != record.ResumePosition.Line {
+					t.Errorf("Record %s: Source lines = %d to %d, want %d to %d", record.Id, source.StartLine, source.EndLine,
+						strings.Count(string(payload[:source.StartOffset]), "\n")+1, record.ResumePosition.Line)
+				}
+				if tt.wantRaw && string(source.Raw) != text {
+					t.Errorf("Record %s: Source.Raw = %q, want %q", record.Id, source.Raw, text)
+				}
+				if !tt.wantRaw && source.Raw != nil {
+					t.Errorf("Record %s: Source.Raw = %q, want nil", record.Id, source.Raw)
+				}
+			}
+			if records != 7 {
+				t.Errorf("RecordReader.AllLossy() yielded %d records, want 7", records)
+			}
+		})
+	}
+}
+
+func TestRecordReader_IterParallelProvenance(t *testing.T) {
+	filename := writeTestLog(t, 20, 7)
+	sequential, err := CreateRecordReader(filename, false)
+	if err != nil {
+		t.Fatalf("CreateRecordReader() error = %v", err)
+	}
+	sequential.SetProvenance(true)
+	want := readAllLossy(sequential)
+	r, err := CreateRecordReader(filename, false)
+	if err != nil {
+		t.Fatalf("CreateRecordReader() error = %v", err)
+	}
+	r.SetProvenance(true)
+	got := collectRecordAndRaws(r.iterChunks(context.Background(), 2, 1))
+	for i := range got {
+		got[i].Err = nil
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RecordReader.iterChunks() =\n is   %v,\n want %v", got, want)
+	}
+	if got[0].Record == nil || got[0].Record.Source == nil || string(got[0].Record.Source.Raw) != got[0].Raw {
+		t.Errorf("RecordReader.iterChunks() = %v, want the source of the first record", got[0])
+	}
+}
+
+func TestRecordReader_SetReassemblyProvenance(t *testing.T) {
+	sectionsOf1 := []string{reassemblyTestSection("00000001", 'A'), reassemblyTestSection("00000001", 'Z')}
+	log := sectionsOf1[0] + reassemblyTestSection("00000002", 'A') + sectionsOf1[1] + reassemblyTestSection("00000002", 'Z')
+	r, err := CreateRecordReaderFromReader(strings.NewReader(log), "test", false)
+	if err != nil {
+		t.Fatalf("CreateRecordReaderFromReader() error = %v", err)
+	}
+	r.SetReassembly(10)
+	r.SetProvenance(true)
+	record, err := r.Next(&strings.Builder{})
+	if err != nil {
+		t.Fatalf("RecordReader.Next() error = %v", err)
+	}
+	want := &Source{
+		File:        "test",
+		StartOffset: 0,
+		EndOffset:   int64(strings.Index(log, reassemblyTestSection("00000002", 'Z'))),
+		StartLine:   1,
+		EndLine:     strings.Count(sectionsOf1[0]+reassemblyTestSection("00000002", 'A')+sectionsOf1[1], "\n"),
+		Raw:         []byte(strings.Join(sectionsOf1, "")),
+	}
+	if record.Id != "00000001" || !reflect.DeepEqual(record.Source, want) {
+		t.Errorf("Record.Source = %+v, want %+v", record.Source, want)
+	}
+}
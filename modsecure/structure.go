@@ -40,22 +40,93 @@ var (
 	}
 )
 
+// Key returns the key of the section, e.g. "A", or an empty string for NIL.
+func (s EStructure) Key() string {
+	for key, value := range keyToEStructure {
+		if value == s {
+			return string(key)
+		}
+	}
+	return ""
+}
+
 //+k8s:openapi-gen=true
 type Record struct {
 	Id                          string                                `json:"id"`
 	AuditHeader                 *SectionAAuditHeader                  `json:"auditHeader"`
 	RequestHeader               *SectionBRequestHeader                `json:"requestHeader"`
 	RequestBody                 []string                              `json:"requestBody"`
+	DecodedRequestBody          *DecodedBody                          `json:"decodedRequestBody"`
 	IntendedResponseHeader      *SectionDIntendedResponseHeader       `json:"intendedResponseHeader"`
 	IntendedResponseBody        *SectionEIntendedResponseBody         `json:"intendedResponseBody"`
 	ResponseHeader              *SectionFResponseHeaders              `json:"responseHeader"`
 	ResponseBody                []string                              `json:"ResponseBody"`
+	DecodedResponseBody         *DecodedResponseBody                  `json:"decodedResponseBody"`
 	AuditLogTrailer             *SectionHAuditLogTrailer              `json:"auditLogTrailer"`
 	ReducedMultipartRequestBody *SectionIReducedMultipartRequestBody  `json:"reducedMultipartRequestBody"`
 	MultipartFilesInformation   *SectionJMultipartFileInformation     `json:"multipartFilesInformation"`
 	MatchedRulesInformation     *SectionKMatchedRuleInformation       `json:"matchedRulesInformation"`
 	AuditLogFooter              *SectionZAuditLogFooter               `json:"auditLogFooter"`
+	RuleMatches                 []*RuleMatch                          `json:"ruleMatches"`
 	RecordLine                  int                                   `json:"recordLine"`
+	Dialect                     EDialect                              `json:"dialect"`
+	Index                       *IndexEntry                           `json:"index"`
+	// Truncations are the parts which were cut by the Limits of the reader.
+	Truncations                 []*Truncation                         `json:"truncations"`
+	// ResumePosition is the position of the reader after this record, see RecordReader.SkipTo.
+	ResumePosition              Position                              `json:"-"`
+	// Source is only set if the reader notes the provenance of the records, see RecordReader.SetProvenance.
+	Source                      *Source                               `json:"source"`
+	// rawIntendedResponseBody and rawResponseBody are the exact text of Section E and G until they are decoded.
+	rawIntendedResponseBody     string
+	rawResponseBody             string
+}
+
+// Source tells where a record was read from.
+//+k8s:openapi-gen=true
+type Source struct {
+	// File is the name of the reader, e.g. the path of the log.
+	File string `json:"file"`
+	// StartOffset and EndOffset are byte positions like Position, EndOffset is the first byte after the record.
+	StartOffset int64 `json:"startOffset"`
+	EndOffset   int64 `json:"endOffset"`
+	// StartLine and EndLine are the numbers of the first and the last line of the record, starting at 1.
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine"`
+	// Raw are the exact bytes of the record. It is empty if a line of the record was cut by the Limits of the reader.
+	Raw []byte `json:"raw"`
+}
+
+// Truncation notes a part of a record which was cut, because it exceeded the Limits of the reader.
+//+k8s:openapi-gen=true
+type Truncation struct {
+	Limit ELimit `json:"limit"`
+	// Section is the key of the section, e.g. "E".
+	Section string `json:"section"`
+	// Line is the line at which the part was cut.
+	Line int `json:"line"`
+	// Max is the exceeded limit in bytes.
+	Max int `json:"max"`
+}
+
+//+k8s:openapi-gen=true
+type IndexEntry struct {
+	Hostname      string    `json:"hostname"`
+	RemoteIP      net.IP    `json:"remoteIp"`
+	RemoteUser    string    `json:"remoteUser"`
+	LocalUser     string    `json:"localUser"`
+	Timestamp     time.Time `json:"timestamp"`
+	RequestLine   string    `json:"requestLine"`
+	Status        uint16    `json:"status"`
+	ResponseSize  uint64    `json:"responseSize"`
+	Referer       string    `json:"referer"`
+	UserAgent     string    `json:"userAgent"`
+	TransactionID string    `json:"transactionId"`
+	SessionID     string    `json:"sessionId"`
+	File          string    `json:"file"`
+	Offset        uint64    `json:"offset"`
+	Size          uint64    `json:"size"`
+	MD5           string    `json:"md5"`
 }
 
 //+k8s:openapi-gen=true
@@ -70,29 +141,73 @@ type SectionAAuditHeader struct {
 
 //+k8s:openapi-gen=true
 type SectionBRequestHeader struct {
-	Protocol string             `json:"protocol"`
-	Method   string             `json:"method"`
-	Path     string             `json:"path"`
-	Header   *map[string]string `json:"header"`
+	Protocol string `json:"protocol"`
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Header   Header `json:"header"`
 }
 
 //+k8s:openapi-gen=true
 type SectionCRequestBody struct {
 }
 
+// DecodedBody is a body decoded according to its Content-Type. Only the field matching ContentType is set.
+//+k8s:openapi-gen=true
+type DecodedBody struct {
+	ContentType string           `json:"contentType"`
+	Form        []*FormField     `json:"form,omitempty"`
+	JSON        interface{}      `json:"json,omitempty"`
+	XML         *XMLNode         `json:"xml,omitempty"`
+	Multipart   []*MultipartPart `json:"multipart,omitempty"`
+	DecodeError string           `json:"decodeError,omitempty"`
+}
+
+//+k8s:openapi-gen=true
+type XMLNode struct {
+	Name       string         `json:"name"`
+	Attributes []XMLAttribute `json:"attributes,omitempty"`
+	Text       string         `json:"text,omitempty"`
+	Children   []*XMLNode     `json:"children,omitempty"`
+}
+
+//+k8s:openapi-gen=true
+type XMLAttribute struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+//+k8s:openapi-gen=true
+type MultipartPart struct {
+	Name        string `json:"name"`
+	FileName    string `json:"fileName,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Value       string `json:"value"`
+}
+
 //+k8s:openapi-gen=true
 type SectionDIntendedResponseHeader struct {
 }
 
 //+k8s:openapi-gen=true
 type SectionEIntendedResponseBody struct {
+	Body    []string             `json:"body"`
+	Decoded *DecodedResponseBody `json:"decoded"`
+}
+
+// DecodedResponseBody is a response body without its transfer and content encoding.
+// Decoded is false if an encoding could not be removed, Body then holds the raw body and Error the reason.
+//+k8s:openapi-gen=true
+type DecodedResponseBody struct {
+	Body    string `json:"body"`
+	Decoded bool   `json:"decoded"`
+	Error   string `json:"error,omitempty"`
 }
 
 //+k8s:openapi-gen=true
 type SectionFResponseHeaders struct {
-	Protocol string             `json:"protocol"`
-	Status   uint16             `json:"status"`
-	Header   *map[string]string `json:"header"`
+	Protocol string `json:"protocol"`
+	Status   uint16 `json:"status"`
+	Header   Header `json:"header"`
 }
 
 //+k8s:openapi-gen=true
@@ -101,18 +216,160 @@ type SectionGResponseBody struct {
 
 //+k8s:openapi-gen=true
 type SectionHAuditLogTrailer struct {
+	Messages                 []string       `json:"messages"`
+	ApacheErrors             []string       `json:"apacheErrors"`
+	ApacheHandler            string         `json:"apacheHandler"`
+	Stopwatch                *Stopwatch     `json:"stopwatch"`
+	Stopwatch2               *Stopwatch2    `json:"stopwatch2"`
+	Producer                 *Producer      `json:"producer"`
+	Server                   string         `json:"server"`
+	EngineMode               string         `json:"engineMode"`
+	Action                   *TrailerAction `json:"action"`
+	WebAppInfo               *WebAppInfo    `json:"webAppInfo"`
+	SanitisedArgs            []string       `json:"sanitisedArgs"`
+	SanitisedRequestHeaders  []string       `json:"sanitisedRequestHeaders"`
+	SanitisedResponseHeaders []string       `json:"sanitisedResponseHeaders"`
+	ResponseBodyTransformed  string         `json:"responseBodyTransformed"`
+	// Other collects trailer lines with a name this parser does not know or with a broken value. Lines without
+	// a name are kept under "".
+	Other map[string][]string `json:"other,omitempty"`
 }
 
+// RuleMatch is a decoded "Message:" line of the audit log trailer.
+// MatchedData, MatchedVariable and MatchedValue are split from a Data value like
+// "Matched Data: union select found within ARGS:q: 1 union select 2".
+//+k8s:openapi-gen=true
+type RuleMatch struct {
+	Disposition     string              `json:"disposition"`
+	Description     string              `json:"description"`
+	File            string              `json:"file"`
+	Line            int                 `json:"line"`
+	ID              string              `json:"id"`
+	Rev             string              `json:"rev"`
+	Msg             string              `json:"msg"`
+	Data            string              `json:"data"`
+	Severity        ESeverity           `json:"severity"`
+	Version         string              `json:"ver"`
+	Maturity        int                 `json:"maturity"`
+	Accuracy        int                 `json:"accuracy"`
+	Tags            []string            `json:"tags"`
+	MatchedData     string              `json:"matchedData"`
+	MatchedVariable string              `json:"matchedVariable"`
+	MatchedValue    string              `json:"matchedValue"`
+	Other           map[string][]string `json:"other,omitempty"`
+}
+
+// Stopwatch is the "Stopwatch:" trailer line. Checkpoints holds the three
+// values in brackets; a "-" in the log is stored as nil.
+//+k8s:openapi-gen=true
+type Stopwatch struct {
+	Start       time.Time        `json:"start"`
+	Duration    time.Duration    `json:"duration"`
+	Checkpoints []*time.Duration `json:"checkpoints"`
+}
+
+// Stopwatch2 is the "Stopwatch2:" trailer line with the per phase timings.
+//+k8s:openapi-gen=true
+type Stopwatch2 struct {
+	Start             time.Time     `json:"start"`
+	Duration          time.Duration `json:"duration"`
+	Combined          time.Duration `json:"combined"`
+	Phase1            time.Duration `json:"phase1"`
+	Phase2            time.Duration `json:"phase2"`
+	Phase3            time.Duration `json:"phase3"`
+	Phase4            time.Duration `json:"phase4"`
+	Phase5            time.Duration `json:"phase5"`
+	StorageRead       time.Duration `json:"storageRead"`
+	StorageWrite      time.Duration `json:"storageWrite"`
+	Logging           time.Duration `json:"logging"`
+	GarbageCollection time.Duration `json:"garbageCollection"`
+}
+
+// Producer is the "Producer:" trailer line, e.g.
+// "ModSecurity for Apache/2.9.2 (http://www.modsecurity.org/); OWASP_CRS/3.0.2."
+//+k8s:openapi-gen=true
+type Producer struct {
+	Engine     string   `json:"engine"`
+	Components []string `json:"components"`
+}
+
+// TrailerAction is the "Action:" trailer line, e.g. "Intercepted (phase 2)".
+//+k8s:openapi-gen=true
+type TrailerAction struct {
+	Name  string `json:"name"`
+	Phase int    `json:"phase"`
+}
+
+// WebAppInfo is the "WebApp-Info:" trailer line. A "-" in the log is stored as "".
+//+k8s:openapi-gen=true
+type WebAppInfo struct {
+	ApplicationID string `json:"applicationId"`
+	SessionID     string `json:"sessionId"`
+	UserID        string `json:"userId"`
+}
+
+// SectionIReducedMultipartRequestBody is the form ModSecurity logs instead of a multipart request body:
+// the form fields url-encoded, without the uploaded files. The files are added as placeholders from
+// Section J when the record contains it.
 //+k8s:openapi-gen=true
 type SectionIReducedMultipartRequestBody struct {
+	Raw    string       `json:"raw"`
+	Fields []*FormField `json:"fields"`
+}
+
+// FormField is a single form argument. For an uploaded file IsFile is set and Value stays empty.
+//+k8s:openapi-gen=true
+type FormField struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	IsFile   bool   `json:"isFile,omitempty"`
+	FileName string `json:"fileName,omitempty"`
+	Size     uint64 `json:"size,omitempty"`
 }
 
 //+k8s:openapi-gen=true
 type SectionJMultipartFileInformation struct {
+	Files     []*MultipartFile `json:"files"`
+	TotalSize uint64           `json:"totalSize"`
+}
+
+// MultipartFile is a line of Section J: index, size, file name and content type of an uploaded file. The path of
+// the temporary file on disk is not written into the audit log, it is only known to rules as FILES_TMPNAMES, so
+// there is no field for it.
+//+k8s:openapi-gen=true
+type MultipartFile struct {
+	Index       int    `json:"index"`
+	// FieldName is taken from the multipart request body of Section C, ModSecurity does not write it into Section J.
+	FieldName   string `json:"fieldName"`
+	FileName    string `json:"fileName"`
+	Size        uint64 `json:"size"`
+	ContentType string `json:"contentType"`
 }
 
 //+k8s:openapi-gen=true
 type SectionKMatchedRuleInformation struct {
+	Rules []*MatchedRule `json:"rules"`
+}
+
+// MatchedRule is a single directive of Section K. Chained rules are grouped under the rule starting the chain.
+// Matched is false for a chained rule which did not match.
+//+k8s:openapi-gen=true
+type MatchedRule struct {
+	Raw       string         `json:"raw"`
+	Directive string         `json:"directive"`
+	Variables string         `json:"variables"`
+	Operator  string         `json:"operator"`
+	Actions   []RuleAction   `json:"actions"`
+	ID        string         `json:"id"`
+	Phase     int            `json:"phase"`
+	Matched   bool           `json:"matched"`
+	Chain     []*MatchedRule `json:"chain"`
+}
+
+//+k8s:openapi-gen=true
+type RuleAction struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
 }
 
 //+k8s:openapi-gen=true
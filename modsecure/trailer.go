@@ -0,0 +1,238 @@
+package modsecure
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// parses: "1538949601019451 23011 (- - -)"
+	stopwatchRegex = regexp.MustCompile(`^([0-9]+)\s([0-9]+)\s\(([^)]*)\)$`)
+	// parses: "1538949601019451 23011; combined=1279, p1=502, p2=641, p3=0, p4=0, p5=136, sr=106, sw=0, l=0, gc=0"
+	stopwatch2Regex = regexp.MustCompile(`^([0-9]+)\s([0-9]+);\s(.*)$`)
+	// parses: "Intercepted (phase 2)"
+	trailerActionRegex = regexp.MustCompile(`^(.+?)(?:\s\(phase\s([0-9]+)\))?$`)
+)
+
+// parseAuditLogTrailer parses Section H. Lines which can not be parsed are kept in Other, a broken value of a
+// known line leaves its field unset.
+func parseAuditLogTrailer(body []string) (section *SectionHAuditLogTrailer, err error) {
+	section = &SectionHAuditLogTrailer{}
+	keep := func(name string, value string) {
+		if section.Other == nil {
+			section.Other = make(map[string][]string)
+		}
+		section.Other[name] = append(section.Other[name], value)
+	}
+	for _, elem := range body {
+		splitterated := strings.SplitN(elem, ": ", 2)
+		if len(splitterated) < 2 {
+			keep("", elem)
+			continue
+		}
+		name, value := splitterated[0], splitterated[1]
+		switch name {
+		case "Message", "ModSecurity":
+			// libmodsecurity 3 writes the messages as "ModSecurity: Warning. ..."
+			section.Messages = append(section.Messages, value)
+		case "Apache-Error":
+			section.ApacheErrors = append(section.ApacheErrors, value)
+		case "Apache-Handler":
+			section.ApacheHandler = value
+		case "Stopwatch":
+			section.Stopwatch, err = parseStopwatch(value)
+		case "Stopwatch2":
+			section.Stopwatch2, err = parseStopwatch2(value)
+		case "Producer":
+			section.Producer = parseProducer(value)
+		case "Server":
+			section.Server = value
+		case "Engine-Mode":
+			section.EngineMode = strings.Trim(value, "\"")
+		case "Action":
+			section.Action, err = parseTrailerAction(value)
+		case "WebApp-Info":
+			section.WebAppInfo, err = parseWebAppInfo(value)
+		case "Sanitised-Args":
+			section.SanitisedArgs, err = splitQuoted(strings.TrimSuffix(value, "."))
+		case "Sanitised-Request-Headers":
+			section.SanitisedRequestHeaders, err = splitQuoted(strings.TrimSuffix(value, "."))
+		case "Sanitised-Response-Headers":
+			section.SanitisedResponseHeaders, err = splitQuoted(strings.TrimSuffix(value, "."))
+		case "Response-Body-Transformed":
+			section.ResponseBodyTransformed = value
+		default:
+			keep(name, value)
+		}
+		if err != nil {
+			keep(name, value)
+			err = nil
+		}
+	}
+	return section, nil
+}
+
+func parseStopwatch(value string) (stopwatch *Stopwatch, err error) {
+	parsedLine := stopwatchRegex.FindStringSubmatch(value)
+	if parsedLine == nil {
+		return nil, errors.New(fmt.Sprintf("Invalid Stopwatch: \"%s\"", value))
+	}
+	start, duration, err := parseStopwatchTimes(parsedLine[1], parsedLine[2])
+	if err != nil {
+		return nil, err
+	}
+	stopwatch = &Stopwatch{
+		Start:       start,
+		Duration:    duration,
+		Checkpoints: make([]*time.Duration, 0, 3),
+	}
+	for _, elem := range strings.Fields(parsedLine[3]) {
+		if elem == "-" {
+			stopwatch.Checkpoints = append(stopwatch.Checkpoints, nil)
+			continue
+		}
+		// Older versions mark a checkpoint with a trailing "*".
+		micros, err := strconv.ParseInt(strings.TrimSuffix(elem, "*"), 10, 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid Stopwatch checkpoint: %s", elem))
+		}
+		checkpoint := time.Duration(micros) * time.Microsecond
+		stopwatch.Checkpoints = append(stopwatch.Checkpoints, &checkpoint)
+	}
+	return stopwatch, nil
+}
+
+func parseStopwatch2(value string) (stopwatch *Stopwatch2, err error) {
+	parsedLine := stopwatch2Regex.FindStringSubmatch(value)
+	if parsedLine == nil {
+		return nil, errors.New(fmt.Sprintf("Invalid Stopwatch2: \"%s\"", value))
+	}
+	start, duration, err := parseStopwatchTimes(parsedLine[1], parsedLine[2])
+	if err != nil {
+		return nil, err
+	}
+	stopwatch = &Stopwatch2{
+		Start:    start,
+		Duration: duration,
+	}
+	fields := map[string]*time.Duration{
+		"combined": &stopwatch.Combined,
+		"p1":       &stopwatch.Phase1,
+		"p2":       &stopwatch.Phase2,
+		"p3":       &stopwatch.Phase3,
+		"p4":       &stopwatch.Phase4,
+		"p5":       &stopwatch.Phase5,
+		"sr":       &stopwatch.StorageRead,
+		"sw":       &stopwatch.StorageWrite,
+		"l":        &stopwatch.Logging,
+		"gc":       &stopwatch.GarbageCollection,
+	}
+	for _, elem := range strings.Split(parsedLine[3], ",") {
+		splitterated := strings.SplitN(strings.TrimSpace(elem), "=", 2)
+		if len(splitterated) < 2 {
+			return nil, errors.New(fmt.Sprintf("Invalid Stopwatch2 value: %s", elem))
+		}
+		field, ok := fields[splitterated[0]]
+		if !ok {
+			continue
+		}
+		micros, err := strconv.ParseInt(splitterated[1], 10, 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid Stopwatch2 value: %s", elem))
+		}
+		*field = time.Duration(micros) * time.Microsecond
+	}
+	return stopwatch, nil
+}
+
+// parseStopwatchTimes parses the start (microseconds since epoch) and the duration (microseconds) of a stopwatch.
+func parseStopwatchTimes(startString, durationString string) (start time.Time, duration time.Duration, err error) {
+	startMicros, err := strconv.ParseInt(startString, 10, 64)
+	if err != nil {
+		return start, duration, errors.New(fmt.Sprintf("Invalid Stopwatch start: %s", startString))
+	}
+	durationMicros, err := strconv.ParseInt(durationString, 10, 64)
+	if err != nil {
+		return start, duration, errors.New(fmt.Sprintf("Invalid Stopwatch duration: %s", durationString))
+	}
+	return time.Unix(0, startMicros*int64(time.Microsecond)).UTC(), time.Duration(durationMicros) * time.Microsecond, nil
+}
+
+func parseProducer(value string) (producer *Producer) {
+	splitterated := strings.Split(strings.TrimSuffix(value, "."), "; ")
+	return &Producer{
+		Engine:     splitterated[0],
+		Components: splitterated[1:],
+	}
+}
+
+func parseTrailerAction(value string) (action *TrailerAction, err error) {
+	parsedLine := trailerActionRegex.FindStringSubmatch(value)
+	if parsedLine == nil {
+		return nil, errors.New(fmt.Sprintf("Invalid Action: \"%s\"", value))
+	}
+	action = &TrailerAction{
+		Name: parsedLine[1],
+	}
+	if parsedLine[2] != "" {
+		action.Phase, err = strconv.Atoi(parsedLine[2])
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid Action phase: %s", parsedLine[2]))
+		}
+	}
+	return action, nil
+}
+
+func parseWebAppInfo(value string) (info *WebAppInfo, err error) {
+	values, err := splitQuoted(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 3 {
+		return nil, errors.New(fmt.Sprintf("Invalid WebApp-Info: \"%s\"", value))
+	}
+	for i, elem := range values {
+		if elem == "-" {
+			values[i] = ""
+		}
+	}
+	return &WebAppInfo{
+		ApplicationID: values[0],
+		SessionID:     values[1],
+		UserID:        values[2],
+	}, nil
+}
+
+// splitQuoted returns the content of all double quoted strings in value, e.g. `"a", "b"` or `"a" "b"`.
+// A backslash escapes the following character inside a quoted string.
+func splitQuoted(value string) (values []string, err error) {
+	values = make([]string, 0, 1)
+	var current strings.Builder
+	inQuote := false
+	escaped := false
+	for _, char := range value {
+		switch {
+		case escaped:
+			current.WriteRune(char)
+			escaped = false
+		case inQuote && char == '\\':
+			escaped = true
+		case char == '"':
+			if inQuote {
+				values = append(values, current.String())
+				current.Reset()
+			}
+			inQuote = !inQuote
+		case inQuote:
+			current.WriteRune(char)
+		}
+	}
+	if inQuote {
+		return nil, errors.New(fmt.Sprintf("Unterminated quote: %s", value))
+	}
+	return values, nil
+}